@@ -0,0 +1,159 @@
+package cec
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultActiveSourceHistorySize - number of active source changes kept
+// by default
+const DefaultActiveSourceHistorySize = 32
+
+// ActiveSourceInfo - the source shown by the TV. LogicalAddress is
+// Unregistered when only the physical address is known and
+// PhysicalAddress is InvalidPhysicalAddress when there is no active source
+type ActiveSourceInfo struct {
+	LogicalAddress  LogicalAddress
+	PhysicalAddress PhysicalAddress
+	Name            string
+	Time            time.Time
+}
+
+type activeSourceTracker struct {
+	mu          sync.Mutex
+	current     ActiveSourceInfo
+	history     []ActiveSourceInfo
+	historySize int
+}
+
+// ActiveSource - the current active source on the bus as seen in
+// ACTIVE_SOURCE, SET_STREAM_PATH, ROUTING_CHANGE, ROUTING_INFORMATION and
+// INACTIVE_SOURCE traffic, false when there is none or it is not known yet
+func (c *Connection) ActiveSource() (ActiveSourceInfo, bool) {
+	c.activeSource.mu.Lock()
+	defer c.activeSource.mu.Unlock()
+
+	current := c.activeSource.current
+	return current, !current.Time.IsZero() && current.PhysicalAddress != InvalidPhysicalAddress
+}
+
+// ActiveSourceHistory - the most recent active source changes, oldest
+// first
+func (c *Connection) ActiveSourceHistory() []ActiveSourceInfo {
+	c.activeSource.mu.Lock()
+	defer c.activeSource.mu.Unlock()
+
+	return append([]ActiveSourceInfo(nil), c.activeSource.history...)
+}
+
+// SetActiveSourceHistorySize - set the number of active source changes
+// kept, 0 disables the history
+func (c *Connection) SetActiveSourceHistorySize(size int) {
+	c.activeSource.mu.Lock()
+	defer c.activeSource.mu.Unlock()
+
+	c.activeSource.historySize = size
+	c.activeSource.trim()
+}
+
+// trim - drop history beyond historySize, must be called with mu held
+func (t *activeSourceTracker) trim() {
+	if over := len(t.history) - t.historySize; over > 0 {
+		t.history = append([]ActiveSourceInfo(nil), t.history[over:]...)
+	}
+}
+
+// trackActiveSource - follow the active source from a received command
+func (c *Connection) trackActiveSource(msg *Command) {
+	params := msg.Parameters.Bytes()
+	physical := func(offset int) PhysicalAddress {
+		return PhysicalAddress(params[offset])<<8 | PhysicalAddress(params[offset+1])
+	}
+
+	switch msg.Opcode {
+	case opcodeActiveSource:
+		if len(params) >= 2 {
+			c.setActiveSource(msg.Initiator, physical(0))
+		}
+	case opcodeSetStreamPath, opcodeRoutingInformation:
+		if len(params) >= 2 {
+			c.setActiveSource(c.addressAt(physical(0)), physical(0))
+		}
+	case opcodeRoutingChange:
+		if len(params) >= 4 {
+			c.setActiveSource(c.addressAt(physical(2)), physical(2))
+		}
+	case opcodeInactiveSource:
+		c.activeSource.mu.Lock()
+		current := c.activeSource.current.LogicalAddress
+		c.activeSource.mu.Unlock()
+		if current == msg.Initiator {
+			c.setActiveSource(Unregistered, InvalidPhysicalAddress)
+		}
+	}
+}
+
+// addressAt - the logical address of the known device at or closest
+// below the given physical address, Unregistered if there is none
+func (c *Connection) addressAt(physical PhysicalAddress) LogicalAddress {
+	var entries []resolverEntry
+	for _, dev := range c.Snapshot() {
+		entries = append(entries, resolverEntry{address: dev.LogicalAddress, physical: dev.PhysicalAddress})
+	}
+	if address, ok := closestBelow(entries, physical); ok {
+		return address
+	}
+	return Unregistered
+}
+
+// announceActiveSource - setActiveSource for callers outside a callback,
+// tracked like a callback so Close doesn't close ActiveSourceChanges while
+// the change is reported
+func (c *Connection) announceActiveSource(address LogicalAddress, physical PhysicalAddress) {
+	if !c.enterCallback() {
+		return
+	}
+	defer c.leaveCallback("activeSource")
+
+	c.setActiveSource(address, physical)
+}
+
+// setActiveSource - record a new active source and report it if it
+// changed, must run in a callback or announceActiveSource
+func (c *Connection) setActiveSource(address LogicalAddress, physical PhysicalAddress) {
+	info := ActiveSourceInfo{
+		LogicalAddress:  address,
+		PhysicalAddress: physical,
+		Time:            time.Now(),
+	}
+	if dev, ok := c.state.device(address); ok && address != Unregistered {
+		info.Name = dev.OSDName
+	}
+
+	c.activeSource.mu.Lock()
+	previous := c.activeSource.current
+	changed := previous.Time.IsZero() || previous.LogicalAddress != address || previous.PhysicalAddress != physical
+	c.activeSource.current = info
+	if changed {
+		c.activeSource.history = append(c.activeSource.history, info)
+		c.activeSource.trim()
+	}
+	c.activeSource.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if address != Unregistered {
+		c.setStateActiveSource(address)
+	}
+
+	slog.Debug("CEC active source changed", "logicalAddress", address, "physicalAddress", physical)
+
+	if c.ActiveSourceChanges != nil {
+		select {
+		case c.ActiveSourceChanges <- &info:
+		case <-c.done:
+		}
+	}
+}
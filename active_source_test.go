@@ -0,0 +1,73 @@
+package cec
+
+import "testing"
+
+func TestTrackActiveSource(t *testing.T) {
+	c := new(Connection)
+	c.SetActiveSourceHistorySize(3)
+
+	if _, ok := c.ActiveSource(); ok {
+		t.Fatalf("no active source should be known yet")
+	}
+
+	c.updateState(command(Playback1, Broadcast, opcodeReportPhysicalAddress, 0x21, 0x00, 0x04))
+	c.updateState(command(Playback1, TV, opcodeSetOSDName, 'P', 'S', '5'))
+	c.updateState(command(AudioSystem, Broadcast, opcodeReportPhysicalAddress, 0x20, 0x00, 0x05))
+
+	c.trackActiveSource(command(AudioSystem, Broadcast, opcodeActiveSource, 0x20, 0x00))
+	c.trackActiveSource(command(TV, Broadcast, opcodeSetStreamPath, 0x21, 0x00))
+
+	current, ok := c.ActiveSource()
+	if !ok || current.LogicalAddress != Playback1 || current.PhysicalAddress != 0x2100 || current.Name != "PS5" {
+		t.Errorf("unexpected active source %+v", current)
+	}
+	if dev, _ := c.state.device(Playback1); !dev.ActiveSource {
+		t.Errorf("cache should mark %v as the active source", Playback1)
+	}
+
+	// repeated announcements are not changes
+	c.trackActiveSource(command(Playback1, Broadcast, opcodeActiveSource, 0x21, 0x00))
+	c.trackActiveSource(command(TV, Broadcast, opcodeRoutingChange, 0x21, 0x00, 0x30, 0x00))
+	c.trackActiveSource(command(Tuner1, Broadcast, opcodeInactiveSource, 0x30, 0x00))
+
+	current, _ = c.ActiveSource()
+	if current.LogicalAddress != Unregistered || current.PhysicalAddress != 0x3000 {
+		t.Errorf("unexpected active source %+v after routing change", current)
+	}
+
+	history := c.ActiveSourceHistory()
+	if len(history) != 3 || history[0].LogicalAddress != AudioSystem || history[2].PhysicalAddress != 0x3000 {
+		t.Errorf("unexpected history %+v", history)
+	}
+
+	c.trackActiveSource(command(Playback2, Broadcast, opcodeActiveSource, 0x30, 0x00))
+	c.trackActiveSource(command(Playback2, TV, opcodeInactiveSource, 0x30, 0x00))
+	if _, ok := c.ActiveSource(); ok {
+		t.Errorf("there should be no active source after INACTIVE_SOURCE")
+	}
+	if history := c.ActiveSourceHistory(); len(history) != 3 {
+		t.Errorf("history should be limited to 3 entries, got %d", len(history))
+	}
+}
+
+func TestTrackActiveSourceBelowSwitch(t *testing.T) {
+	c := new(Connection)
+	// a switch on HDMI 2 with a player on its input 1, which has a
+	// recorder connected to it
+	c.updateState(command(Playback1, Broadcast, opcodeReportPhysicalAddress, 0x21, 0x10, 0x04))
+	c.updateState(command(Recording1, Broadcast, opcodeReportPhysicalAddress, 0x21, 0x11, 0x01))
+
+	for _, tt := range []struct {
+		msg  *Command
+		want LogicalAddress
+	}{
+		{command(TV, Broadcast, opcodeRoutingChange, 0x00, 0x00, 0x21, 0x00), Playback1},
+		{command(TV, Broadcast, opcodeSetStreamPath, 0x21, 0x11), Recording1},
+		{command(TV, Broadcast, opcodeSetStreamPath, 0x22, 0x00), Unregistered},
+	} {
+		c.trackActiveSource(tt.msg)
+		if current, _ := c.ActiveSource(); current.LogicalAddress != tt.want {
+			t.Errorf("%s: active source %v, want %v", tt.msg.Operation, current.LogicalAddress, tt.want)
+		}
+	}
+}
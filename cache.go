@@ -0,0 +1,219 @@
+package cec
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL - how long device properties learned from bus traffic or
+// queries are served without asking the device again
+const DefaultCacheTTL = time.Minute
+
+// names of the cached Device properties, also used as Device.Errors keys
+const (
+	fieldPhysicalAddress = "PhysicalAddress"
+	fieldOSDName         = "OSDName"
+	fieldVendorID        = "VendorID"
+	fieldCECVersion      = "CECVersion"
+	fieldMenuLanguage    = "MenuLanguage"
+	fieldPowerStatus     = "PowerStatus"
+	fieldActiveSource    = "ActiveSource"
+	fieldDeviceType      = "DeviceType"
+)
+
+type cachedDevice struct {
+	device  Device
+	updated map[string]time.Time
+}
+
+type stateCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	devices [16]*cachedDevice
+}
+
+// SetCacheTTL - set how long cached device properties are considered
+// fresh, 0 disables serving reads from the cache
+func (c *Connection) SetCacheTTL(ttl time.Duration) {
+	c.state.mu.Lock()
+	defer c.state.mu.Unlock()
+
+	c.state.ttl = ttl
+}
+
+// Snapshot - the devices known from bus traffic and earlier queries,
+// ordered by logical address, without sending anything on the bus
+func (c *Connection) Snapshot() []Device {
+	c.state.mu.Lock()
+	defer c.state.mu.Unlock()
+
+	devices := []Device{}
+	for _, cached := range c.state.devices {
+		if cached != nil {
+			devices = append(devices, cached.device)
+		}
+	}
+	return devices
+}
+
+// device - the cached state of the device at address
+func (s *stateCache) device(address LogicalAddress) (Device, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !address.IsValid() || s.devices[address] == nil {
+		return Device{}, false
+	}
+	return s.devices[address].device, true
+}
+
+// get - the cached entry for address, created if missing. Must be called
+// with mu held
+func (s *stateCache) get(address LogicalAddress) *cachedDevice {
+	cached := s.devices[address]
+	if cached == nil {
+		cached = &cachedDevice{
+			device: Device{
+				LogicalAddress:  address,
+				DeviceType:      address.DeviceType(),
+				PowerStatus:     PowerStatusUnknown,
+				PhysicalAddress: InvalidPhysicalAddress,
+			},
+			updated: make(map[string]time.Time),
+		}
+		s.devices[address] = cached
+	}
+	return cached
+}
+
+// update - set a property of the device at address
+func (s *stateCache) update(address LogicalAddress, field string, fn func(*Device)) {
+	if !address.IsValid() || address == Broadcast {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	cached := s.get(address)
+	fn(&cached.device)
+	cached.updated[field] = now
+	cached.device.LastSeen = now
+}
+
+// seen - record that the device at address is alive
+func (s *stateCache) seen(address LogicalAddress) {
+	if !address.IsValid() || address == Broadcast {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.get(address).device.LastSeen = time.Now()
+}
+
+// fresh - the cached device if the given property was updated within the
+// TTL
+func (s *stateCache) fresh(address LogicalAddress, field string) (Device, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !address.IsValid() || s.devices[address] == nil {
+		return Device{}, false
+	}
+	cached := s.devices[address]
+	updated, ok := cached.updated[field]
+	if !ok || time.Since(updated) >= s.ttl {
+		return Device{}, false
+	}
+	return cached.device, true
+}
+
+// invalidate - make the next read of a property go to the bus
+func (s *stateCache) invalidate(address LogicalAddress, field string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, cached := range s.devices {
+		if cached != nil && (address == Broadcast || LogicalAddress(i) == address) {
+			delete(cached.updated, field)
+		}
+	}
+}
+
+// updateState - learn device properties from a received command
+func (c *Connection) updateState(msg *Command) {
+	params := msg.Parameters.Bytes()
+	initiator := msg.Initiator
+
+	c.state.seen(initiator)
+
+	switch msg.Opcode {
+	case opcodeReportPowerStatus:
+		if len(params) >= 1 {
+			c.state.update(initiator, fieldPowerStatus, func(d *Device) {
+				d.PowerStatus = PowerStatus(params[0])
+			})
+		}
+	case opcodeReportPhysicalAddress:
+		if len(params) >= 3 {
+			c.state.update(initiator, fieldPhysicalAddress, func(d *Device) {
+				d.PhysicalAddress = PhysicalAddress(params[0])<<8 | PhysicalAddress(params[1])
+			})
+			c.state.update(initiator, fieldDeviceType, func(d *Device) {
+				d.DeviceType = DeviceType(params[2])
+			})
+		}
+	case opcodeActiveSource:
+		if len(params) >= 2 {
+			c.state.update(initiator, fieldPhysicalAddress, func(d *Device) {
+				d.PhysicalAddress = PhysicalAddress(params[0])<<8 | PhysicalAddress(params[1])
+			})
+		}
+		c.setStateActiveSource(initiator)
+	case opcodeInactiveSource:
+		c.state.update(initiator, fieldActiveSource, func(d *Device) {
+			d.ActiveSource = false
+		})
+	case opcodeSetOSDName:
+		c.state.update(initiator, fieldOSDName, func(d *Device) {
+			d.OSDName = string(params)
+		})
+	case opcodeDeviceVendorID:
+		if len(params) >= 3 {
+			c.state.update(initiator, fieldVendorID, func(d *Device) {
+				d.VendorID = uint64(params[0])<<16 | uint64(params[1])<<8 | uint64(params[2])
+				d.Vendor = GetVendorByID(d.VendorID)
+			})
+		}
+	case opcodeCECVersion:
+		if len(params) >= 1 {
+			c.state.update(initiator, fieldCECVersion, func(d *Device) {
+				d.CECVersion = CECVersion(params[0])
+			})
+		}
+	case opcodeSetMenuLanguage:
+		if len(params) >= 3 {
+			c.state.update(initiator, fieldMenuLanguage, func(d *Device) {
+				d.MenuLanguage = string(params[:3])
+			})
+		}
+	}
+}
+
+// setStateActiveSource - mark address as the only active source
+func (c *Connection) setStateActiveSource(address LogicalAddress) {
+	c.state.mu.Lock()
+	for i, cached := range c.state.devices {
+		if cached != nil && LogicalAddress(i) != address {
+			cached.device.ActiveSource = false
+		}
+	}
+	c.state.mu.Unlock()
+
+	c.state.update(address, fieldActiveSource, func(d *Device) {
+		d.ActiveSource = true
+	})
+}
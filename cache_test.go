@@ -0,0 +1,56 @@
+package cec
+
+import (
+	"testing"
+	"time"
+)
+
+func command(initiator LogicalAddress, destination LogicalAddress, opcode int, params ...byte) *Command {
+	return &Command{
+		Initiator:   initiator,
+		Destination: destination,
+		Opcode:      opcode,
+		OpcodeSet:   1,
+		Parameters:  DataPacket{Data: params, Size: len(params)},
+	}
+}
+
+func TestStateCacheFromTraffic(t *testing.T) {
+	c := new(Connection)
+	c.SetCacheTTL(time.Minute)
+
+	c.updateState(command(Playback1, Broadcast, opcodeReportPhysicalAddress, 0x21, 0x00, 0x04))
+	c.updateState(command(Playback1, TV, opcodeSetOSDName, 'P', 'S', '5'))
+	c.updateState(command(Playback1, Broadcast, opcodeDeviceVendorID, 0x08, 0x00, 0x46))
+	c.updateState(command(Playback1, TV, opcodeReportPowerStatus, 0x00))
+	c.updateState(command(AudioSystem, Broadcast, opcodeActiveSource, 0x20, 0x00))
+	c.updateState(command(Playback1, Broadcast, opcodeActiveSource, 0x21, 0x00))
+
+	devices := c.Snapshot()
+	if len(devices) != 2 {
+		t.Fatalf("Snapshot() returned %d devices, want 2", len(devices))
+	}
+
+	dev := devices[0]
+	if dev.LogicalAddress != Playback1 || dev.PhysicalAddress != 0x2100 || dev.OSDName != "PS5" ||
+		dev.Vendor != "Sony" || dev.PowerStatus != PowerStatusOn || !dev.ActiveSource ||
+		dev.DeviceType != DeviceTypePlayback {
+		t.Errorf("unexpected device %+v", dev)
+	}
+	if devices[1].ActiveSource {
+		t.Errorf("%v should no longer be the active source", devices[1].LogicalAddress)
+	}
+
+	if _, ok := c.state.fresh(Playback1, fieldOSDName); !ok {
+		t.Errorf("OSD name should be fresh")
+	}
+	c.state.invalidate(Playback1, fieldOSDName)
+	if _, ok := c.state.fresh(Playback1, fieldOSDName); ok {
+		t.Errorf("OSD name should not be fresh after invalidate")
+	}
+
+	c.SetCacheTTL(0)
+	if _, ok := c.state.fresh(Playback1, fieldPowerStatus); ok {
+		t.Errorf("nothing should be fresh with a TTL of 0")
+	}
+}
@@ -13,21 +13,35 @@ func logMessageCallback(c unsafe.Pointer, msg *C.cec_log_message) C.int {
 	slog.Debug("CEC msg rx", "message", C.GoString(msg.message))
 
 	conn := (*Connection)(c)
+	if !conn.enterCallback() {
+		return 0
+	}
+	defer conn.leaveCallback("logMessage")
+
 	conn.messageReceived(C.GoString(msg.message))
 	return 0
 }
 
 //export keyPressed
-func keyPressed(c unsafe.Pointer, code *C.cec_keypress) C.int {
+func keyPressed(c unsafe.Pointer, code *C.cec_keypress) {
 	slog.Debug("CEC keycode rx", "code", code)
 
-	conn := (*Connection)(c)
-	keyPress := &KeyPress{
-		KeyCode:  int(C.int(code.keycode)),
+	(*Connection)(c).handleKeyPress(&KeyPress{
+		KeyCode:  KeyCode(code.keycode),
 		Duration: int(code.duration),
+	})
+}
+
+// handleKeyPress - guarded keyPressed, libcec's key press callback returns
+// nothing so the handler's result only decides whether the key reaches
+// KeyPresses
+func (c *Connection) handleKeyPress(k *KeyPress) {
+	if !c.enterCallback() {
+		return
 	}
-	conn.keyPressed(keyPress)
-	return 0
+	defer c.leaveCallback("keyPress")
+
+	c.keyPressed(k)
 }
 
 //export commandReceived
@@ -35,21 +49,53 @@ func commandReceived(c unsafe.Pointer, msg *C.cec_command) C.int {
 	slog.Debug("CEC command rx", "msg", msg)
 
 	conn := (*Connection)(c)
-	cmd := &Command{
-		Initiator:       uint32(msg.initiator),
-		Destination:     uint32(msg.destination),
+	if !conn.enterCallback() {
+		return 0
+	}
+	defer conn.leaveCallback("commandReceived")
+
+	conn.commandReceived(newCommand(msg))
+
+	return 0
+}
+
+//export commandHandler
+func commandHandler(c unsafe.Pointer, msg *C.cec_command) C.int {
+	if (*Connection)(c).handleCommand(newCommand(msg)) {
+		return 1
+	}
+	return 0
+}
+
+// handleCommand - guarded commandHandled, returning true tells libcec not
+// to take any action
+func (c *Connection) handleCommand(msg *Command) bool {
+	if !c.enterCallback() {
+		return false
+	}
+	defer c.leaveCallback("commandHandler")
+
+	return c.commandHandled(msg)
+}
+
+func newCommand(msg *C.cec_command) *Command {
+	params := make([]byte, int(msg.parameters.size))
+	for i := range params {
+		params[i] = byte(msg.parameters.data[i])
+	}
+
+	return &Command{
+		Initiator:       LogicalAddress(msg.initiator),
+		Destination:     LogicalAddress(msg.destination),
 		Ack:             int8(msg.ack),
 		Eom:             int8(msg.eom),
 		Opcode:          int(msg.opcode),
-		Parameters:      DataPacket{Data: msg.parameters.data, Size: int(msg.parameters.size)},
+		Parameters:      DataPacket{Data: params, Size: len(params)},
 		OpcodeSet:       int8(msg.opcode_set),
 		TransmitTimeout: int32(msg.transmit_timeout),
 		Operation:       opcodes[int(msg.opcode)],
 		CommandString:   CreateCommandString(msg),
 	}
-	conn.commandReceived(cmd)
-
-	return 0
 }
 
 //export alertReceived
@@ -62,17 +108,35 @@ func alertReceived(c unsafe.Pointer, alert_type C.libcec_alert, cec_param C.libc
 //export sourceActivated
 func sourceActivated(c unsafe.Pointer, logicalAddress C.cec_logical_address, activated int) {
 	conn := (*Connection)(c)
+	if !conn.enterCallback() {
+		return
+	}
+	defer conn.leaveCallback("sourceActivated")
+
 	src := &SourceActivation{
-		LogicalAddress:     int(logicalAddress),
-		LogicalAddressName: GetLogicalNameByAddress(int(logicalAddress)),
+		LogicalAddress:     LogicalAddress(logicalAddress),
+		LogicalAddressName: LogicalAddress(logicalAddress).String(),
 		State:              activated == 1}
 	conn.sourceActivated(src)
 }
 
 //export menuStateChanged
 func menuStateChanged(c unsafe.Pointer, state C.cec_menu_state) C.uint8_t {
-	conn := (*Connection)(c)
-	// menuState is bool, 0 = activated, 1 = deactivated
-	conn.menuActivated(int(state) == 0)
-	return 1
+	if (*Connection)(c).handleMenuState(MenuState(state)) {
+		return 1
+	}
+	return 0
+}
+
+// handleMenuState - guarded menuStateChanged, returning false refuses the
+// change
+func (c *Connection) handleMenuState(state MenuState) (accept bool) {
+	if !c.enterCallback() {
+		return false
+	}
+	// accept the change if the handler panics, as before handlers existed
+	accept = true
+	defer c.leaveCallback("menuStateChanged")
+
+	return c.menuStateChanged(state)
 }
@@ -3,5 +3,103 @@ package cec
 import "testing"
 
 func TestKeypress(t *testing.T) {
+	c := &Connection{KeyPresses: make(chan *KeyPress, 2), done: make(chan struct{})}
 
+	var handled []KeyCode
+	c.OnKeyPress = func(k KeyPress) bool {
+		handled = append(handled, k.KeyCode)
+		return k.KeyCode == KeySelect
+	}
+
+	c.handleKeyPress(&KeyPress{KeyCode: KeySelect})
+	c.handleKeyPress(&KeyPress{KeyCode: KeyUp, Duration: 500})
+
+	if len(handled) != 2 {
+		t.Errorf("OnKeyPress called for %v, want both keys", handled)
+	}
+	if len(c.KeyPresses) != 1 {
+		t.Fatalf("KeyPresses holds %d keys, want only the unhandled one", len(c.KeyPresses))
+	}
+	if k := <-c.KeyPresses; k.KeyCode != KeyUp || k.Duration != 500 {
+		t.Errorf("KeyPresses got %+v", k)
+	}
+}
+
+func TestCommandHandler(t *testing.T) {
+	c := &Connection{done: make(chan struct{})}
+
+	if c.handleCommand(command(TV, Broadcast, opcodeActiveSource, 0x10, 0x00)) {
+		t.Error("handleCommand() = true without OnCommand")
+	}
+
+	c.OnCommand = func(cmd Command) bool {
+		return cmd.Opcode == opcodeStandby
+	}
+	if c.handleCommand(command(TV, Broadcast, opcodeActiveSource, 0x10, 0x00)) {
+		t.Error("handleCommand() = true, OnCommand returned false")
+	}
+	if !c.handleCommand(command(TV, Broadcast, opcodeStandby)) {
+		t.Error("handleCommand() = false, OnCommand returned true")
+	}
+
+	var panics []*CallbackPanic
+	c.OnPanic = func(p *CallbackPanic) { panics = append(panics, p) }
+	c.OnCommand = func(Command) bool { panic("boom") }
+	if c.handleCommand(command(TV, Broadcast, opcodeStandby)) {
+		t.Error("handleCommand() = true after a panic")
+	}
+	if len(panics) != 1 || panics[0].Callback != "commandHandler" {
+		t.Errorf("OnPanic got %+v", panics)
+	}
+}
+
+func TestMenuStateHandler(t *testing.T) {
+	c := &Connection{MenuActivations: make(chan bool, 4), done: make(chan struct{})}
+
+	if !c.handleMenuState(MenuStateActivated) {
+		t.Error("handleMenuState() = false without OnMenuStateChanged")
+	}
+
+	c.OnMenuStateChanged = func(state MenuState) bool {
+		return state == MenuStateDeactivated
+	}
+	if c.handleMenuState(MenuStateActivated) {
+		t.Error("handleMenuState() = true, OnMenuStateChanged refused")
+	}
+	if !c.handleMenuState(MenuStateDeactivated) {
+		t.Error("handleMenuState() = false, OnMenuStateChanged accepted")
+	}
+
+	var panics []*CallbackPanic
+	c.OnPanic = func(p *CallbackPanic) { panics = append(panics, p) }
+	c.OnMenuStateChanged = func(MenuState) bool { panic("boom") }
+	if !c.handleMenuState(MenuStateActivated) {
+		t.Error("handleMenuState() = false after a panic, want the change accepted")
+	}
+	if len(panics) != 1 || panics[0].Callback != "menuStateChanged" {
+		t.Errorf("OnPanic got %+v", panics)
+	}
+
+	// refused changes are not reported
+	close(c.MenuActivations)
+	var got []bool
+	for v := range c.MenuActivations {
+		got = append(got, v)
+	}
+	if len(got) != 2 || got[0] != true || got[1] != false {
+		t.Errorf("MenuActivations got %v, want [true false]", got)
+	}
+}
+
+func TestClosedConnectionDropsCallbacks(t *testing.T) {
+	c := &Connection{KeyPresses: make(chan *KeyPress, 1), done: make(chan struct{}), closed: true}
+	c.OnMenuStateChanged = func(MenuState) bool { t.Error("handler called after close"); return true }
+
+	c.handleKeyPress(&KeyPress{KeyCode: KeySelect})
+	if c.handleMenuState(MenuStateActivated) {
+		t.Error("handleMenuState() = true after close")
+	}
+	if len(c.KeyPresses) != 0 {
+		t.Error("key press delivered after close")
+	}
 }
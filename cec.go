@@ -1,9 +1,12 @@
 package cec
 
 import (
-	"encoding/hex"
+	"context"
+	"fmt"
 	"log/slog"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -11,41 +14,80 @@ import (
 type Device struct {
 	OSDName         string
 	Vendor          string
-	LogicalAddress  int
+	VendorID        uint64
+	LogicalAddress  LogicalAddress
+	DeviceType      DeviceType
 	ActiveSource    bool
-	PowerStatus     string
-	PhysicalAddress string
+	PowerStatus     PowerStatus
+	PhysicalAddress PhysicalAddress
+	CECVersion      CECVersion
+	MenuLanguage    string
+	// OwnAdapter is set for the addresses claimed by our own adapter
+	OwnAdapter bool
+	// LastSeen is the time of the last message from or answer by the device
+	LastSeen time.Time
+	// Errors holds the reason a property could not be retrieved, keyed by
+	// the name of the property
+	Errors map[string]error
 }
 
 type Command struct {
-	Initiator       uint32     /**< the logical address of the initiator of this message */
-	Destination     uint32     /**< the logical address of the destination of this message */
-	Ack             int8       /**< 1 when the ACK bit is set, 0 otherwise */
-	Eom             int8       /**< 1 when the EOM bit is set, 0 otherwise */
-	Opcode          int        /**< the opcode of this message */
-	Parameters      DataPacket /**< the parameters attached to this message */
-	OpcodeSet       int8       /**< 1 when an opcode is set, 0 otherwise (POLL message) */
-	TransmitTimeout int32      /**< the timeout to use in ms */
+	Initiator       LogicalAddress /**< the logical address of the initiator of this message */
+	Destination     LogicalAddress /**< the logical address of the destination of this message */
+	Ack             int8           /**< 1 when the ACK bit is set, 0 otherwise */
+	Eom             int8           /**< 1 when the EOM bit is set, 0 otherwise */
+	Opcode          int            /**< the opcode of this message */
+	Parameters      DataPacket     /**< the parameters attached to this message */
+	OpcodeSet       int8           /**< 1 when an opcode is set, 0 otherwise (POLL message) */
+	TransmitTimeout int32          /**< the timeout to use in ms */
 	Operation       string
 	CommandString   string
 }
 
+// DataPacket - the parameters of a command, Data holds them as []byte
 type DataPacket struct {
 	Data interface{}
 	Size int
 }
 
+// Bytes - get the parameters as a byte slice
+func (p DataPacket) Bytes() []byte {
+	if b, ok := p.Data.([]byte); ok {
+		return b
+	}
+	return nil
+}
+
 type SourceActivation struct {
-	LogicalAddress     int
+	LogicalAddress     LogicalAddress
 	LogicalAddressName string
 	State              bool
 }
 
 type KeyPress struct {
-	KeyCode  int
+	KeyCode  KeyCode
 	Duration int
 }
 
+// MenuState - state of the device menu as requested by the TV
+type MenuState int
+
+const (
+	MenuStateActivated   MenuState = 0
+	MenuStateDeactivated MenuState = 1
+)
+
+// CallbackPanic - a panic recovered in a callback invoked by libcec
+type CallbackPanic struct {
+	Callback string
+	Value    interface{}
+	Stack    []byte
+}
+
+func (p *CallbackPanic) Error() string {
+	return fmt.Sprintf("panic in %s callback: %v", p.Callback, p.Value)
+}
+
 var logicalNames = []string{"TV", "Recording", "Recording2", "Tuner",
 	"Playback", "Audio", "Tuner2", "Tuner3",
 	"Playback2", "Recording3", "Tuner4", "Playback3",
@@ -60,13 +102,34 @@ var vendorList = map[uint64]string{0x000039: "Toshiba", 0x0000F0: "Samsung",
 	0x18C086: "Broadcom", 0x6B746D: "Vizio", 0x8065E9: "Benq",
 	0x9C645E: "Harman Kardon"}
 
-var cecDeviceType = map[int]string{
-	0: "CEC_DEVICE_TYPE_TV",
-	1: "CEC_DEVICE_TYPE_RECORDING_DEVICE",
-	2: "CEC_DEVICE_TYPE_RESERVED",
-	3: "CEC_DEVICE_TYPE_TUNER",
-	4: "CEC_DEVICE_TYPE_PLAYBACK_DEVICE",
-	5: "CEC_DEVICE_TYPE_AUDIO_SYSTEM"}
+// opcodes used by the package itself
+const (
+	opcodeFeatureAbort           = 0x00
+	opcodeImageViewOn            = 0x04
+	opcodeTextViewOn             = 0x0D
+	opcodeSetMenuLanguage        = 0x32
+	opcodeStandby                = 0x36
+	opcodeUserControlPressed     = 0x44
+	opcodeUserControlRelease     = 0x45
+	opcodeGiveOSDName            = 0x46
+	opcodeSetOSDName             = 0x47
+	opcodeRoutingChange          = 0x80
+	opcodeRoutingInformation     = 0x81
+	opcodeActiveSource           = 0x82
+	opcodeGivePhysicalAddress    = 0x83
+	opcodeReportPhysicalAddress  = 0x84
+	opcodeSetStreamPath          = 0x86
+	opcodeDeviceVendorID         = 0x87
+	opcodeVendorRemoteButtonDown = 0x8A
+	opcodeVendorRemoteButtonUp   = 0x8B
+	opcodeGiveDeviceVendorID     = 0x8C
+	opcodeGivePowerStatus        = 0x8F
+	opcodeReportPowerStatus      = 0x90
+	opcodeGetMenuLanguage        = 0x91
+	opcodeInactiveSource         = 0x9D
+	opcodeCECVersion             = 0x9E
+	opcodeGetCECVersion          = 0x9F
+)
 
 var opcodes = map[int]string{
 	0x82: "ACTIVE_SOURCE",
@@ -144,32 +207,12 @@ var opcodes = map[int]string{
 	0xFD: "NONE",
 }
 
-var keyList = map[int]string{0x00: "Select", 0x01: "Up", 0x02: "Down", 0x03: "Left",
-	0x04: "Right", 0x05: "RightUp", 0x06: "RightDown", 0x07: "LeftUp",
-	0x08: "LeftDown", 0x09: "RootMenu", 0x0A: "SetupMenu", 0x0B: "ContentsMenu",
-	0x0C: "FavoriteMenu", 0x0D: "Exit", 0x20: "0", 0x21: "1", 0x22: "2", 0x23: "3",
-	0x24: "4", 0x25: "5", 0x26: "6", 0x27: "7", 0x28: "8", 0x29: "9", 0x2A: "Dot",
-	0x2B: "Enter", 0x2C: "Clear", 0x2F: "NextFavorite", 0x30: "ChannelUp",
-	0x31: "ChannelDown", 0x32: "PreviousChannel", 0x33: "SoundSelect",
-	0x34: "InputSelect", 0x35: "DisplayInformation", 0x36: "Help",
-	0x37: "PageUp", 0x38: "PageDown", 0x40: "Power", 0x41: "VolumeUp",
-	0x42: "VolumeDown", 0x43: "Mute", 0x44: "Play", 0x45: "Stop", 0x46: "Pause",
-	0x47: "Record", 0x48: "Rewind", 0x49: "FastForward", 0x4A: "Eject",
-	0x4B: "Forward", 0x4C: "Backward", 0x4D: "StopRecord", 0x4E: "PauseRecord",
-	0x50: "Angle", 0x51: "SubPicture", 0x52: "VideoOnDemand",
-	0x53: "ElectronicProgramGuide", 0x54: "TimerProgramming",
-	0x55: "InitialConfiguration", 0x60: "PlayFunction", 0x61: "PausePlay",
-	0x62: "RecordFunction", 0x63: "PauseRecordFunction",
-	0x64: "StopFunction", 0x65: "Mute",
-	0x66: "RestoreVolume", 0x67: "Tune", 0x68: "SelectMedia",
-	0x69: "SelectAvInput", 0x6A: "SelectAudioInput", 0x6B: "PowerToggle",
-	0x6C: "PowerOff", 0x6D: "PowerOn", 0x71: "Blue", 0x72: "Red", 0x73: "Green",
-	0x74: "Yellow", 0x75: "F5", 0x76: "Data", 0x91: "AnReturn",
-	0x96: "Max"}
-
 // Open - open a new connection to the CEC device with the given name
 func Open(name string, deviceName string) (*Connection, error) {
 	c := new(Connection)
+	c.done = make(chan struct{})
+	c.state.ttl = DefaultCacheTTL
+	c.activeSource.historySize = DefaultActiveSourceHistorySize
 
 	var err error
 
@@ -184,6 +227,7 @@ func Open(name string, deviceName string) (*Connection, error) {
 	adapter, err := getAdapter(c.connection, name)
 	if err != nil {
 		slog.Error("Error retrieving adapter", "error", err)
+		c.Close(context.Background())
 		return nil, err
 	}
 
@@ -192,6 +236,7 @@ func Open(name string, deviceName string) (*Connection, error) {
 	err = openAdapter(c.connection, adapter)
 	if err != nil {
 		slog.Error("Error opening adapter", "error", err)
+		c.Close(context.Background())
 		return nil, err
 	}
 
@@ -200,71 +245,303 @@ func Open(name string, deviceName string) (*Connection, error) {
 	return c, nil
 }
 
-// Key - send key press and release commands (hold key for 10ms) to the device
-// at the given address, the key code can be specified as a hex-code or by
-// its name
-func (c *Connection) Key(address int, key interface{}) {
-	var keycode int
-
-	switch key := key.(type) {
-	case string:
-		if key[:2] == "0x" && len(key) == 4 {
-			keybytes, err := hex.DecodeString(key[2:])
-			if err != nil {
-				slog.Error("Could not decode key", "error", err)
-				return
+// OpenContext - like Open, but gives up when ctx is done. A connection
+// that is opened after ctx is done is closed again
+func OpenContext(ctx context.Context, name string, deviceName string) (*Connection, error) {
+	type result struct {
+		c   *Connection
+		err error
+	}
+	opened := make(chan result, 1)
+
+	go func() {
+		c, err := Open(name, deviceName)
+		opened <- result{c, err}
+	}()
+
+	select {
+	case r := <-opened:
+		return r.c, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-opened; r.c != nil {
+				r.c.Close(context.Background())
 			}
-			keycode = int(keybytes[0])
-		} else {
-			keycode = GetKeyCodeByName(key)
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// callTrackerKey - context key for the WaitGroup set by trackCalls
+type callTrackerKey struct{}
+
+// trackCalls - make call count the libcec calls made with the returned
+// context in calls, including the ones still running after ctx is done
+func trackCalls(ctx context.Context, calls *sync.WaitGroup) context.Context {
+	return context.WithValue(ctx, callTrackerKey{}, calls)
+}
+
+// call - run a blocking libcec call, giving up when ctx is done. libcec
+// calls can't be cancelled, the call keeps running in the background and
+// Close waits for it before freeing the connection
+func (c *Connection) call(ctx context.Context, fn func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.calls.Add(1)
+	c.mu.Unlock()
+
+	tracker, _ := ctx.Value(callTrackerKey{}).(*sync.WaitGroup)
+	if tracker != nil {
+		tracker.Add(1)
+	}
+	finished := func() {
+		if tracker != nil {
+			tracker.Done()
 		}
-	case int:
-		keycode = key
-	default:
-		slog.Error("Invalid key type", "keytype", key)
-		return
+		c.calls.Done()
 	}
-	er := c.KeyPress(address, keycode)
-	if er != nil {
-		slog.Error("Error handling key press", "error", er)
-		return
+
+	if ctx.Done() == nil {
+		defer finished()
+		fn()
+		return nil
 	}
-	time.Sleep(10 * time.Millisecond)
-	er = c.KeyRelease(address)
-	if er != nil {
-		slog.Error("Error handling key release", "error", er)
+
+	done := make(chan struct{})
+	go func() {
+		defer finished()
+		fn()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Key - send key press and release commands to the device at the given
+// address, holding the key for the device's press time (see SetKeyTiming).
+// The key can be given by name, as a hex-code string, a KeyCode or a
+// UICommand
+func (c *Connection) Key(address LogicalAddress, key interface{}) error {
+	return c.KeyContext(context.Background(), address, key)
+}
+
+// KeyContext - like Key, but gives up when ctx is done, a pressed key is
+// always released
+func (c *Connection) KeyContext(ctx context.Context, address LogicalAddress, key interface{}) error {
+	command, err := keyCommand(key)
+	if err != nil {
+		return err
+	}
+
+	err = c.KeyPressContext(ctx, address, command.Key, command.Operands...)
+	if err != nil {
+		return fmt.Errorf("Error handling key press: %w", err)
+	}
+
+	sleep(ctx, c.KeyTiming(address).Press)
+
+	err = c.KeyReleaseContext(context.WithoutCancel(ctx), address)
+	if err != nil {
+		return fmt.Errorf("Error handling key release: %w", err)
+	}
+	return ctx.Err()
+}
+
+// enterCallback - register a running callback, returns false once the
+// connection is closed and events must be dropped
+func (c *Connection) enterCallback() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return false
+	}
+	c.dispatch.Add(1)
+	return true
+}
+
+// leaveCallback - must be deferred directly after a successful
+// enterCallback, recovers and reports panics so they don't take down the
+// process from a libcec thread
+func (c *Connection) leaveCallback(callback string) {
+	defer c.dispatch.Done()
+
+	if r := recover(); r != nil {
+		p := &CallbackPanic{Callback: callback, Value: r, Stack: debug.Stack()}
+		slog.Error("Panic in CEC callback", "callback", callback, "panic", r, "stack", string(p.Stack))
+		c.reportPanic(p)
+	}
+}
+
+func (c *Connection) reportPanic(p *CallbackPanic) {
+	if c.OnPanic == nil {
 		return
 	}
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Panic in OnPanic handler", "panic", r)
+		}
+	}()
+	c.OnPanic(p)
+}
+
+func (c *Connection) closeChannels() {
+	if c.Commands != nil {
+		close(c.Commands)
+	}
+	if c.KeyPresses != nil {
+		close(c.KeyPresses)
+	}
+	if c.Messages != nil {
+		close(c.Messages)
+	}
+	if c.SourceActivations != nil {
+		close(c.SourceActivations)
+	}
+	if c.MenuActivations != nil {
+		close(c.MenuActivations)
+	}
+	if c.ActiveSourceChanges != nil {
+		close(c.ActiveSourceChanges)
+	}
 }
 
 func (c *Connection) commandReceived(msg *Command) {
 	slog.Debug("CEC command", "opcodeIdx", msg.Opcode, "opcode", opcodes[msg.Opcode])
 
+	c.recordFeatureAbort(msg)
+	c.updateState(msg)
+	c.trackActiveSource(msg)
+	c.notifyListeners(msg)
+
 	if c.Commands != nil {
-		c.Commands <- msg
+		select {
+		case c.Commands <- msg:
+		case <-c.done:
+		}
+	}
+}
+
+// listen - call fn for every received command until remove is called
+func (c *Connection) listen(fn func(*Command)) (remove func()) {
+	c.listenMu.Lock()
+	defer c.listenMu.Unlock()
+
+	if c.listeners == nil {
+		c.listeners = make(map[int]func(*Command))
+	}
+	id := c.nextListener
+	c.nextListener++
+	c.listeners[id] = fn
+
+	return func() {
+		c.listenMu.Lock()
+		defer c.listenMu.Unlock()
+		delete(c.listeners, id)
+	}
+}
+
+// listenKeyPresses - call fn for every key reported by libcec's key press
+// callback until remove is called
+func (c *Connection) listenKeyPresses(fn func(*KeyPress)) (remove func()) {
+	c.listenMu.Lock()
+	defer c.listenMu.Unlock()
+
+	if c.keyListeners == nil {
+		c.keyListeners = make(map[int]func(*KeyPress))
+	}
+	id := c.nextListener
+	c.nextListener++
+	c.keyListeners[id] = fn
+
+	return func() {
+		c.listenMu.Lock()
+		defer c.listenMu.Unlock()
+		delete(c.keyListeners, id)
 	}
 }
 
+func (c *Connection) notifyListeners(msg *Command) {
+	c.listenMu.Lock()
+	listeners := make([]func(*Command), 0, len(c.listeners))
+	for _, fn := range c.listeners {
+		listeners = append(listeners, fn)
+	}
+	c.listenMu.Unlock()
+
+	for _, fn := range listeners {
+		fn(msg)
+	}
+}
+
+func (c *Connection) commandHandled(msg *Command) bool {
+	if c.OnCommand != nil {
+		return c.OnCommand(*msg)
+	}
+	return false
+}
+
 func (c *Connection) messageReceived(msg string) {
 	if c.Messages != nil {
-		c.Messages <- msg
+		select {
+		case c.Messages <- msg:
+		case <-c.done:
+		}
 	}
 }
 
 func (c *Connection) keyPressed(k *KeyPress) {
 	slog.Debug("CEC key pressed", "key", k)
 
+	c.listenMu.Lock()
+	listeners := make([]func(*KeyPress), 0, len(c.keyListeners))
+	for _, fn := range c.keyListeners {
+		listeners = append(listeners, fn)
+	}
+	c.listenMu.Unlock()
+
+	for _, fn := range listeners {
+		fn(k)
+	}
+
+	if c.OnKeyPress != nil && c.OnKeyPress(*k) {
+		return
+	}
+
 	if c.KeyPresses != nil {
-		c.KeyPresses <- k
+		select {
+		case c.KeyPresses <- k:
+		case <-c.done:
+		}
 	}
 }
 
-func (c *Connection) menuActivated(s bool) {
-	slog.Debug("CEC menu activated", "state", s)
+func (c *Connection) menuStateChanged(state MenuState) bool {
+	slog.Debug("CEC menu state changed", "state", state)
+
+	if c.OnMenuStateChanged != nil && !c.OnMenuStateChanged(state) {
+		return false
+	}
 
 	if c.MenuActivations != nil {
-		c.MenuActivations <- s
+		select {
+		case c.MenuActivations <- state == MenuStateActivated:
+		case <-c.done:
+		}
 	}
+	return true
 }
 
 func (c *Connection) sourceActivated(src *SourceActivation) {
@@ -273,32 +550,36 @@ func (c *Connection) sourceActivated(src *SourceActivation) {
 		"logicalAddressName", src.LogicalAddressName,
 		"state", src.State)
 
+	if src.State {
+		if physical, err := c.GetDevicePhysicalAddressContext(context.Background(), src.LogicalAddress); err == nil {
+			c.setActiveSource(src.LogicalAddress, physical)
+		}
+	}
+
 	if c.SourceActivations != nil {
-		c.SourceActivations <- src
+		select {
+		case c.SourceActivations <- src:
+		case <-c.done:
+		}
 	}
 }
 
 // List - list active devices (returns a map of Devices)
 func (c *Connection) List() map[string]Device {
-	devices := make(map[string]Device)
-
-	activeDevices := c.GetActiveDevices()
-
-	for address, active := range activeDevices {
-		if active {
-			var dev Device
+	devices, _ := c.ListContext(context.Background())
+	return devices
+}
 
-			dev.LogicalAddress = address
-			dev.PhysicalAddress = c.GetDevicePhysicalAddress(address)
-			dev.OSDName = c.GetDeviceOSDName(address)
-			dev.PowerStatus = c.GetDevicePowerStatus(address)
-			dev.ActiveSource = c.IsActiveSource(address)
-			dev.Vendor = GetVendorByID(c.GetDeviceVendorID(address))
+// ListContext - like List, but gives up when ctx is done. Properties a
+// device doesn't report are left empty
+func (c *Connection) ListContext(ctx context.Context) (map[string]Device, error) {
+	devices := make(map[string]Device)
 
-			devices[logicalNames[address]] = dev
-		}
+	list, err := c.DevicesContext(ctx)
+	for _, dev := range list {
+		devices[dev.LogicalAddress.String()] = dev
 	}
-	return devices
+	return devices, err
 }
 
 // removeSeparators - remove separators (":", "-", " ", "_")
@@ -313,47 +594,30 @@ func removeSeparators(in string) string {
 	return (out)
 }
 
-// GetKeyCodeByName - get the keycode by its name
+// GetKeyCodeByName - get the keycode by its name, returns -1 for unknown
+// names
 func GetKeyCodeByName(name string) int {
-	name = removeSeparators(name)
-	name = strings.ToLower(name)
-
-	for code, value := range keyList {
-		if strings.ToLower(value) == name {
-			return code
-		}
+	code, err := ParseKeyCode(name)
+	if err != nil {
+		return -1
 	}
 
-	return -1
+	return int(code)
 }
 
-// GetLogicalAddressByName - get logical address by its name
+// GetLogicalAddressByName - get logical address by its name, returns -1
+// for unknown names
 func GetLogicalAddressByName(name string) int {
-	name = removeSeparators(name)
-	l := len(name)
-
-	if name[l-1] == '1' {
-		name = name[:l-1]
-	}
-
-	name = strings.ToLower(name)
-
-	for i := 0; i < 16; i++ {
-		if strings.ToLower(logicalNames[i]) == name {
-			return i
-		}
-	}
-
-	if name == "unregistered" {
-		return 15
+	address, err := ParseLogicalAddress(name)
+	if err != nil {
+		return -1
 	}
-
-	return -1
+	return int(address)
 }
 
 // GetLogicalNameByAddress - get logical name by address
 func GetLogicalNameByAddress(addr int) string {
-	return logicalNames[addr]
+	return LogicalAddress(addr).String()
 }
 
 // GetVendorByID - Get vendor by ID
@@ -0,0 +1,231 @@
+package cec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CECVersion - version of the CEC specification a device implements
+type CECVersion int
+
+const (
+	CECVersionUnknown CECVersion = 0x00
+	CECVersion1_2     CECVersion = 0x01
+	CECVersion1_2A    CECVersion = 0x02
+	CECVersion1_3     CECVersion = 0x03
+	CECVersion1_3A    CECVersion = 0x04
+	CECVersion1_4     CECVersion = 0x05
+	CECVersion2_0     CECVersion = 0x06
+)
+
+var cecVersionNames = map[CECVersion]string{
+	CECVersionUnknown: "unknown",
+	CECVersion1_2:     "1.2",
+	CECVersion1_2A:    "1.2a",
+	CECVersion1_3:     "1.3",
+	CECVersion1_3A:    "1.3a",
+	CECVersion1_4:     "1.4",
+	CECVersion2_0:     "2.0",
+}
+
+func (v CECVersion) String() string {
+	if name, ok := cecVersionNames[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("CECVersion(0x%02X)", int(v))
+}
+
+// DiscoveryOptions - limits for querying devices
+type DiscoveryOptions struct {
+	// Concurrency is the number of devices queried at the same time, the
+	// bus carries one message at a time so more rarely helps
+	Concurrency int
+	// DeviceTimeout bounds the time spent querying a single device
+	DeviceTimeout time.Duration
+	// Timeout bounds the whole discovery, zero waits as long as ctx allows
+	Timeout time.Duration
+}
+
+// DefaultDiscoveryOptions - used by Devices and List
+var DefaultDiscoveryOptions = DiscoveryOptions{
+	Concurrency:   3,
+	DeviceTimeout: 3 * time.Second,
+}
+
+// Devices - list all active devices ordered by logical address, including
+// our own adapter
+func (c *Connection) Devices() ([]Device, error) {
+	return c.DevicesContext(context.Background())
+}
+
+// DevicesContext - like Devices, but gives up when ctx is done
+func (c *Connection) DevicesContext(ctx context.Context) ([]Device, error) {
+	return c.DiscoverDevices(ctx, DefaultDiscoveryOptions)
+}
+
+// DiscoverDevices - query all active devices concurrently. Properties a
+// device doesn't report in time are left empty and the reason is stored
+// in Device.Errors. When ctx is done or opts.Timeout passes every active
+// device is still returned, with the properties not queried in time
+// failing with the context error, together with that error (ErrTimeout
+// for opts.Timeout)
+func (c *Connection) DiscoverDevices(ctx context.Context, opts DiscoveryOptions) ([]Device, error) {
+	parent := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	devices, err := c.discoverDevices(ctx, opts)
+	if err != nil && parent.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: discovery took longer than %s", ErrTimeout, opts.Timeout)
+	}
+	return devices, err
+}
+
+func (c *Connection) discoverDevices(ctx context.Context, opts DiscoveryOptions) ([]Device, error) {
+	active, err := c.GetActiveDevicesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	own, err := c.getOwnAddresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []LogicalAddress
+	for i := range active {
+		if active[i] || own[i] {
+			addresses = append(addresses, LogicalAddress(i))
+		}
+	}
+
+	devices := discover(ctx, addresses, opts, func(ctx context.Context, address LogicalAddress) Device {
+		dev := c.queryDevice(ctx, address)
+		dev.OwnAdapter = own[address]
+		return dev
+	})
+
+	if err := ctx.Err(); err != nil {
+		return devices, err
+	}
+	for _, dev := range devices {
+		for _, err := range dev.Errors {
+			if errors.Is(err, ErrClosed) {
+				return devices, err
+			}
+		}
+	}
+	return devices, nil
+}
+
+// discover - run query for every address, at most opts.Concurrency at a
+// time. A slot is only given to the next device once the libcec calls
+// made with the query's context have returned, calls abandoned on
+// DeviceTimeout keep the bus busy until then. Every address gets a
+// Device, queried with a done context once ctx is done
+func discover(ctx context.Context, addresses []LogicalAddress, opts DiscoveryOptions,
+	query func(ctx context.Context, address LogicalAddress) Device) []Device {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	found := make([]Device, len(addresses))
+	for i, address := range addresses {
+		wg.Add(1)
+		go func(i int, address LogicalAddress) {
+			defer wg.Done()
+
+			deviceCtx := ctx
+			select {
+			case sem <- struct{}{}:
+				calls := new(sync.WaitGroup)
+				deviceCtx = trackCalls(deviceCtx, calls)
+				defer func() {
+					go func() {
+						calls.Wait()
+						<-sem
+					}()
+				}()
+			case <-ctx.Done():
+			}
+
+			if opts.DeviceTimeout > 0 {
+				var cancel context.CancelFunc
+				deviceCtx, cancel = context.WithTimeout(deviceCtx, opts.DeviceTimeout)
+				defer cancel()
+			}
+			found[i] = query(deviceCtx, address)
+		}(i, address)
+	}
+	wg.Wait()
+
+	return found
+}
+
+// queryDevice - get all properties of the device at the given address one
+// after the other, failures are recorded in Device.Errors
+func (c *Connection) queryDevice(ctx context.Context, address LogicalAddress) Device {
+	dev := Device{
+		LogicalAddress: address,
+		DeviceType:     address.DeviceType(),
+		PowerStatus:    PowerStatusUnknown,
+	}
+
+	queries := []struct {
+		field string
+		query func() error
+	}{
+		{fieldPhysicalAddress, func() (err error) {
+			dev.PhysicalAddress, err = c.GetDevicePhysicalAddressContext(ctx, address)
+			return err
+		}},
+		{fieldOSDName, func() (err error) {
+			dev.OSDName, err = c.GetDeviceOSDNameContext(ctx, address)
+			return err
+		}},
+		{fieldVendorID, func() (err error) {
+			dev.VendorID, err = c.GetDeviceVendorIDContext(ctx, address)
+			dev.Vendor = GetVendorByID(dev.VendorID)
+			return err
+		}},
+		{fieldCECVersion, func() (err error) {
+			dev.CECVersion, err = c.GetDeviceCECVersionContext(ctx, address)
+			return err
+		}},
+		{fieldMenuLanguage, func() (err error) {
+			dev.MenuLanguage, err = c.GetDeviceMenuLanguageContext(ctx, address)
+			return err
+		}},
+		{fieldPowerStatus, func() (err error) {
+			dev.PowerStatus, err = c.GetDevicePowerStatusContext(ctx, address)
+			return err
+		}},
+		{fieldActiveSource, func() (err error) {
+			dev.ActiveSource, err = c.IsActiveSourceContext(ctx, address)
+			return err
+		}},
+	}
+
+	for _, q := range queries {
+		err := ctx.Err()
+		if err == nil {
+			err = q.query()
+		}
+		if err != nil {
+			if dev.Errors == nil {
+				dev.Errors = make(map[string]error)
+			}
+			dev.Errors[q.field] = err
+		}
+	}
+
+	return dev
+}
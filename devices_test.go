@@ -0,0 +1,100 @@
+package cec
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDiscoverHoldsSlotUntilCallReturns(t *testing.T) {
+	c := new(Connection)
+	release := make(chan struct{})
+	returned := make(chan time.Time, 1)
+	started := make(chan time.Time, 1)
+
+	opts := DiscoveryOptions{Concurrency: 1, DeviceTimeout: 20 * time.Millisecond}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+	}()
+
+	var first sync.Once
+	devices := discover(context.Background(), []LogicalAddress{TV, Playback1}, opts,
+		func(ctx context.Context, address LogicalAddress) Device {
+			dev := Device{LogicalAddress: address}
+			blocking := false
+			first.Do(func() { blocking = true })
+			if blocking {
+				// a libcec call that outlives the device timeout
+				err := c.call(ctx, func() {
+					<-release
+					returned <- time.Now()
+				})
+				dev.Errors = map[string]error{fieldOSDName: err}
+			} else {
+				started <- time.Now()
+			}
+			return dev
+		})
+
+	timedOut := 0
+	for _, dev := range devices {
+		if errors.Is(dev.Errors[fieldOSDName], context.DeadlineExceeded) {
+			timedOut++
+		}
+	}
+	if len(devices) != 2 || timedOut != 1 {
+		t.Fatalf("discover() = %+v", devices)
+	}
+	if end, start := <-returned, <-started; start.Before(end) {
+		t.Errorf("next device queried %v before the abandoned call returned", end.Sub(start))
+	}
+}
+
+func TestDiscoverReportsEveryDevice(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	queried := 0
+
+	devices := discover(ctx, []LogicalAddress{TV, Playback1, AudioSystem}, DiscoveryOptions{Concurrency: 1},
+		func(ctx context.Context, address LogicalAddress) Device {
+			mu.Lock()
+			queried++
+			mu.Unlock()
+			// the first device ends the discovery while the others wait
+			// for a slot
+			cancel()
+			return Device{LogicalAddress: address, Errors: map[string]error{fieldOSDName: ctx.Err()}}
+		})
+
+	if queried != 3 || len(devices) != 3 {
+		t.Fatalf("discover() = %d devices after %d queries, want every device", len(devices), queried)
+	}
+	for i, want := range []LogicalAddress{TV, Playback1, AudioSystem} {
+		if devices[i].LogicalAddress != want || !errors.Is(devices[i].Errors[fieldOSDName], context.Canceled) {
+			t.Errorf("devices[%d] = %+v", i, devices[i])
+		}
+	}
+}
+
+func TestDiscoverDevicesTimeout(t *testing.T) {
+	bus := newFakeBus(Playback1)
+	bus.c.hooks.activeDevices = func() []LogicalAddress {
+		time.Sleep(100 * time.Millisecond)
+		return []LogicalAddress{TV}
+	}
+
+	_, err := bus.c.DiscoverDevices(context.Background(), DiscoveryOptions{Timeout: 20 * time.Millisecond})
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("DiscoverDevices() = %v, want ErrTimeout", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = bus.c.DiscoverDevices(ctx, DiscoveryOptions{Timeout: time.Second})
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrTimeout) {
+		t.Errorf("DiscoverDevices() = %v, want the context error", err)
+	}
+}
@@ -0,0 +1,107 @@
+package cec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors returned by Connection methods, test for them with errors.Is
+var (
+	// ErrNoAdapter - no CEC adapter matching the requested name was found
+	ErrNoAdapter = errors.New("No CEC adapter found")
+	// ErrNotPresent - the device at the given address is not on the bus
+	ErrNotPresent = errors.New("Device not present")
+	// ErrTimeout - the device is present but did not answer in time
+	ErrTimeout = errors.New("Device did not answer")
+	// ErrNack - a message was not acknowledged by its destination
+	ErrNack = errors.New("Message not acknowledged")
+	// ErrFeatureAbort - the device answered with a FEATURE_ABORT
+	ErrFeatureAbort = errors.New("Feature aborted by device")
+	// ErrClosed - the connection has been closed
+	ErrClosed = errors.New("Connection closed")
+	// ErrNotSwitched - an input switch did not take effect
+	ErrNotSwitched = errors.New("Input switch did not take effect")
+)
+
+type featureAbortKey struct {
+	address LogicalAddress
+	opcode  int
+}
+
+// recordFeatureAbort - remember a FEATURE_ABORT so that a failing query
+// for the aborted opcode can report ErrFeatureAbort
+func (c *Connection) recordFeatureAbort(msg *Command) {
+	params := msg.Parameters.Bytes()
+	if msg.Opcode != opcodeFeatureAbort || len(params) < 1 {
+		return
+	}
+
+	c.abortMu.Lock()
+	defer c.abortMu.Unlock()
+
+	if c.aborts == nil {
+		c.aborts = make(map[featureAbortKey]time.Time)
+	}
+	c.aborts[featureAbortKey{msg.Initiator, int(params[0])}] = time.Now()
+}
+
+func (c *Connection) featureAborted(address LogicalAddress, opcode int, since time.Time) bool {
+	c.abortMu.Lock()
+	defer c.abortMu.Unlock()
+
+	at, ok := c.aborts[featureAbortKey{address, opcode}]
+	return ok && !at.Before(since)
+}
+
+// how long queryFailed waits for a FEATURE_ABORT from a present device,
+// libcec returns from the query before the abort reaches us through the
+// command callback
+var featureAbortWait = 50 * time.Millisecond
+
+// queryFailed - work out why a query for opcode sent to address at since
+// didn't produce a value
+func (c *Connection) queryFailed(ctx context.Context, address LogicalAddress, opcode int, since time.Time) error {
+	aborted := make(chan struct{}, 1)
+	remove := c.listen(func(msg *Command) {
+		params := msg.Parameters.Bytes()
+		if msg.Opcode == opcodeFeatureAbort && msg.Initiator == address && len(params) >= 1 && int(params[0]) == opcode {
+			select {
+			case aborted <- struct{}{}:
+			default:
+			}
+		}
+	})
+	defer remove()
+
+	abort := fmt.Errorf("%w: %s", ErrFeatureAbort, opcodes[opcode])
+	if c.featureAborted(address, opcode, since) {
+		return abort
+	}
+
+	active, err := c.isActiveDevice(ctx, address)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return ErrNotPresent
+	}
+
+	wait := time.NewTimer(featureAbortWait)
+	defer wait.Stop()
+	select {
+	case <-aborted:
+		return abort
+	case <-wait.C:
+		return ErrTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isFatal - check if err will fail every following query too, as opposed
+// to a single device not answering
+func isFatal(ctx context.Context, err error) bool {
+	return err != nil && (errors.Is(err, ErrClosed) || ctx.Err() != nil)
+}
@@ -0,0 +1,88 @@
+package cec
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueryFailed(t *testing.T) {
+	abortPower := command(Playback1, Recording1, opcodeFeatureAbort, opcodeGivePowerStatus, 0x00)
+
+	tests := []struct {
+		name    string
+		present bool
+		// abort is delivered while the query runs (0), after it returned
+		// (> 0) or not at all (< 0)
+		abort time.Duration
+		msg   *Command
+		want  error
+	}{
+		{"aborted", true, 0, abortPower, ErrFeatureAbort},
+		{"aborted late", true, 10 * time.Millisecond, abortPower, ErrFeatureAbort},
+		{"other opcode aborted", true, 0, command(Playback1, Recording1, opcodeFeatureAbort, opcodeGiveOSDName, 0x00), ErrTimeout},
+		{"other device aborted", true, 0, command(Playback2, Recording1, opcodeFeatureAbort, opcodeGivePowerStatus, 0x00), ErrTimeout},
+		{"no answer", true, -1, nil, ErrTimeout},
+		{"not present", false, -1, nil, ErrNotPresent},
+	}
+
+	for _, tt := range tests {
+		bus := newFakeBus(Recording1)
+		bus.setPresent(Playback1, tt.present)
+		bus.setPower(Playback1, PowerStatusUnknown)
+		powerStatus := bus.c.hooks.powerStatus
+		bus.c.hooks.powerStatus = func(address LogicalAddress) PowerStatus {
+			switch {
+			case tt.abort == 0:
+				bus.receive(tt.msg)
+			case tt.abort > 0:
+				go func() {
+					time.Sleep(tt.abort)
+					bus.receive(tt.msg)
+				}()
+			}
+			return powerStatus(address)
+		}
+
+		_, err := bus.c.GetDevicePowerStatusContext(context.Background(), Playback1)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: GetDevicePowerStatusContext() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// an abort from before the query doesn't count
+	bus := newFakeBus(Recording1)
+	bus.setPresent(Playback1, true)
+	bus.setPower(Playback1, PowerStatusUnknown)
+	bus.receive(abortPower)
+	time.Sleep(time.Millisecond)
+	if _, err := bus.c.GetDevicePowerStatusContext(context.Background(), Playback1); !errors.Is(err, ErrTimeout) {
+		t.Errorf("GetDevicePowerStatusContext() after an old abort = %v, want ErrTimeout", err)
+	}
+}
+
+func TestOSDName(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+		err  error
+	}{
+		{"Player", "Player", nil},
+		{"Player\x00\x00junk", "Player", nil},
+		{"TV  \x00", "TV", nil},
+		{"  \x00TV", "", ErrTimeout},
+		{"", "", ErrTimeout},
+	}
+
+	for _, tt := range tests {
+		bus := newFakeBus(Recording1)
+		bus.setPresent(Playback1, true)
+		bus.c.hooks.osdName = func(LogicalAddress) string { return tt.raw }
+
+		got, err := bus.c.GetDeviceOSDNameContext(context.Background(), Playback1)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("OSD name %q = %q, %v, want %q, %v", tt.raw, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+//go:build linux
+
+package cec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+// evdev ioctls, see linux/input.h
+const eviocGrab = 0x40044590
+
+// EvdevSource - reads keys from a Linux input device
+type EvdevSource struct {
+	f      *os.File
+	keymap map[uint16]KeyCode
+}
+
+// OpenEvdev - read keys from the input device at path, e.g.
+// /dev/input/event3. A nil keymap uses the reverse of DefaultInputKeymap,
+// grab keeps the keys from reaching other applications
+func OpenEvdev(path string, keymap map[uint16]KeyCode, grab bool) (*EvdevSource, error) {
+	if keymap == nil {
+		keymap = reverseInputKeymap(DefaultInputKeymap)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if grab {
+		if err := ioctl(f, eviocGrab, 1); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return &EvdevSource{f: f, keymap: keymap}, nil
+}
+
+// ReadKey - the next press, repeat or release of a mapped key, blocks
+// until a key arrives or the source is closed
+func (s *EvdevSource) ReadKey(ctx context.Context) (InputKey, error) {
+	var ev inputEvent
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&ev)), unsafe.Sizeof(ev))
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return InputKey{}, err
+		}
+		n, err := s.f.Read(buf)
+		if err != nil {
+			return InputKey{}, err
+		}
+		if n != len(buf) {
+			return InputKey{}, fmt.Errorf("Short read from input device")
+		}
+		if ev.Type != evKey || ev.Value < 0 || ev.Value > 2 {
+			continue
+		}
+		key, ok := s.keymap[ev.Code]
+		if !ok {
+			continue
+		}
+		return InputKey{Key: key, State: KeyState(ev.Value)}, nil
+	}
+}
+
+// Close - close the input device
+func (s *EvdevSource) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,172 @@
+package cec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// KeyState - what happened to a key read from a KeySource
+type KeyState int
+
+const (
+	// KeyStateUp - the key was released
+	KeyStateUp KeyState = 0
+	// KeyStateDown - the key was pressed and is held until KeyStateUp, for
+	// the held key it is a new press
+	KeyStateDown KeyState = 1
+	// KeyStateRepeat - the source repeated a held key
+	KeyStateRepeat KeyState = 2
+	// KeyStateTap - the key was pressed and released at once
+	KeyStateTap KeyState = 3
+)
+
+// InputKey - a key read from a KeySource
+type InputKey struct {
+	Key   KeyCode
+	State KeyState
+}
+
+// KeySource - an input the keys forwarded to the CEC bus are read from,
+// ReadKey returns io.EOF when there are no more keys
+type KeySource interface {
+	ReadKey(ctx context.Context) (InputKey, error)
+	Close() error
+}
+
+// keySender - sends keys to the forwarding destination
+type keySender interface {
+	press(ctx context.Context, key KeyCode) error
+	release(ctx context.Context) error
+	tap(ctx context.Context, key KeyCode) error
+}
+
+type connectionSender struct {
+	c           *Connection
+	destination LogicalAddress
+}
+
+func (s connectionSender) press(ctx context.Context, key KeyCode) error {
+	return s.c.KeyPressContext(ctx, s.destination, key)
+}
+
+func (s connectionSender) release(ctx context.Context) error {
+	return s.c.KeyReleaseContext(ctx, s.destination)
+}
+
+func (s connectionSender) tap(ctx context.Context, key KeyCode) error {
+	return s.c.KeyContext(ctx, s.destination, key)
+}
+
+// Forward - send the keys read from the sources to the device at
+// destination until ctx is done or all sources are exhausted. A held key
+// is repeated at the destination's repeat interval (see SetKeyTiming),
+// independent of how often the source repeats it. The sources are closed
+// when Forward returns
+func (c *Connection) Forward(ctx context.Context, destination LogicalAddress, sources ...KeySource) error {
+	sender := connectionSender{c: c, destination: destination}
+	return forwardKeys(ctx, sender, c.KeyTiming(destination).Repeat, sources...)
+}
+
+func forwardKeys(ctx context.Context, sender keySender, repeat time.Duration, sources ...KeySource) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	keys := make(chan InputKey)
+	var wg sync.WaitGroup
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source KeySource) {
+			defer wg.Done()
+			for {
+				key, err := source.ReadKey(ctx)
+				if err != nil {
+					if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+						slog.Error("Error reading key", "error", err)
+					}
+					return
+				}
+				select {
+				case keys <- key:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(source)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	defer func() {
+		cancel()
+		for _, source := range sources {
+			source.Close()
+		}
+		wg.Wait()
+	}()
+
+	var held KeyCode
+	holding := false
+	ticker := time.NewTicker(repeat)
+	ticker.Stop()
+	defer ticker.Stop()
+
+	release := func() {
+		if !holding {
+			return
+		}
+		holding = false
+		ticker.Stop()
+		// always release a pressed key
+		if err := sender.release(context.WithoutCancel(ctx)); err != nil {
+			slog.Error("Error forwarding key release", "error", err)
+		}
+	}
+	defer release()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-finished:
+			// the sources also stop when ctx is done
+			return ctx.Err()
+		case <-ticker.C:
+			if holding {
+				if err := sender.press(ctx, held); err != nil {
+					slog.Error("Error repeating key", "key", held, "error", err)
+				}
+			}
+		case key := <-keys:
+			switch key.State {
+			case KeyStateDown, KeyStateRepeat:
+				if holding && held == key.Key && key.State == KeyStateRepeat {
+					// repeated at our own interval
+					continue
+				}
+				release()
+				if err := sender.press(ctx, key.Key); err != nil {
+					slog.Error("Error forwarding key", "key", key.Key, "error", err)
+					continue
+				}
+				held, holding = key.Key, true
+				ticker.Reset(repeat)
+			case KeyStateUp:
+				if holding && held == key.Key {
+					release()
+				}
+			case KeyStateTap:
+				release()
+				if err := sender.tap(ctx, key.Key); err != nil {
+					slog.Error("Error forwarding key", "key", key.Key, "error", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package cec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeKeySource struct {
+	keys   chan InputKey
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newFakeKeySource() *fakeKeySource {
+	return &fakeKeySource{keys: make(chan InputKey), closed: make(chan struct{})}
+}
+
+func (s *fakeKeySource) ReadKey(ctx context.Context) (InputKey, error) {
+	select {
+	case key, ok := <-s.keys:
+		if !ok {
+			return InputKey{}, io.EOF
+		}
+		return key, nil
+	case <-s.closed:
+		return InputKey{}, io.EOF
+	case <-ctx.Done():
+		return InputKey{}, ctx.Err()
+	}
+}
+
+func (s *fakeKeySource) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+type fakeKeySender struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (s *fakeKeySender) add(format string, args ...interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (s *fakeKeySender) press(ctx context.Context, key KeyCode) error {
+	return s.add("press %s", key)
+}
+
+func (s *fakeKeySender) release(ctx context.Context) error {
+	return s.add("release")
+}
+
+func (s *fakeKeySender) tap(ctx context.Context, key KeyCode) error {
+	return s.add("tap %s", key)
+}
+
+func (s *fakeKeySender) count(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, sent := range s.sent {
+		if strings.HasPrefix(sent, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestForwardKeys(t *testing.T) {
+	source := newFakeKeySource()
+	sender := new(fakeKeySender)
+
+	done := make(chan error)
+	go func() {
+		done <- forwardKeys(context.Background(), sender, 20*time.Millisecond, source)
+	}()
+
+	source.keys <- InputKey{Key: KeySelect, State: KeyStateTap}
+	source.keys <- InputKey{Key: KeyUp, State: KeyStateDown}
+	// fast source repeats are replaced by our own
+	for i := 0; i < 10; i++ {
+		source.keys <- InputKey{Key: KeyUp, State: KeyStateRepeat}
+	}
+	time.Sleep(110 * time.Millisecond)
+	source.keys <- InputKey{Key: KeyUp, State: KeyStateUp}
+	// a new key releases the held one, as does a new press of it
+	source.keys <- InputKey{Key: KeyLeft, State: KeyStateDown}
+	source.keys <- InputKey{Key: KeyRight, State: KeyStateDown}
+	source.keys <- InputKey{Key: KeyRight, State: KeyStateDown}
+	close(source.keys)
+
+	if err := <-done; err != nil {
+		t.Fatalf("forwardKeys() = %v", err)
+	}
+
+	presses := sender.count("press Up")
+	if presses < 2 || presses > 10 {
+		t.Errorf("Up pressed %d times in 110ms with a 20ms repeat interval", presses)
+	}
+
+	var rest []string
+	for _, sent := range sender.sent {
+		if sent != "press Up" {
+			rest = append(rest, sent)
+		}
+	}
+	want := []string{"tap Select", "release", "press Left", "release", "press Right", "release", "press Right", "release"}
+	if !reflect.DeepEqual(rest, want) {
+		t.Errorf("sent %q, want %q", rest, want)
+	}
+}
+
+func TestForwardKeysCancel(t *testing.T) {
+	source := newFakeKeySource()
+	sender := new(fakeKeySender)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		done <- forwardKeys(ctx, sender, time.Second, source)
+	}()
+
+	source.keys <- InputKey{Key: KeyVolumeUp, State: KeyStateDown}
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Errorf("forwardKeys() = %v, want %v", err, context.Canceled)
+	}
+	if want := []string{"press VolumeUp", "release"}; !reflect.DeepEqual(sender.sent, want) {
+		t.Errorf("sent %q, want %q", sender.sent, want)
+	}
+	select {
+	case <-source.closed:
+	default:
+		t.Error("source not closed")
+	}
+}
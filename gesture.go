@@ -0,0 +1,441 @@
+package cec
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultGestureTimeout - maximum time between the release of a key and
+// the press of the next key of a gesture when Gesture.Timeout is 0
+const DefaultGestureTimeout = time.Second
+
+// GestureStep - one key of a gesture, Hold is the minimum time it has to
+// be held
+type GestureStep struct {
+	Key  KeyCode
+	Hold time.Duration
+}
+
+// Gesture - a named key sequence on a single remote
+type Gesture struct {
+	Name  string
+	Steps []GestureStep
+	// Timeout is the maximum time between the release of a key and the
+	// press of the next one
+	Timeout time.Duration
+	// Swallow holds back the key events of a partially matched gesture,
+	// they are dropped when the gesture completes and passed on otherwise
+	Swallow bool
+}
+
+// GestureEvent - a registered gesture was recognized
+type GestureEvent struct {
+	Name      string
+	Initiator LogicalAddress
+	Time      time.Time
+}
+
+func (ev GestureEvent) keyEvent() (KeyInfo, bool) {
+	return KeyInfo{Initiator: ev.Initiator, Time: ev.Time}, false
+}
+
+// ParseGestureSteps - parse keys separated by spaces or commas, a key
+// followed by a colon and a duration has to be held that long, e.g.
+// "Red Red Green" or "Stop:3s Power"
+func ParseGestureSteps(s string) ([]GestureStep, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("Empty gesture")
+	}
+
+	steps := make([]GestureStep, 0, len(fields))
+	for _, field := range fields {
+		var step GestureStep
+		name, hold, ok := strings.Cut(field, ":")
+		if ok {
+			d, err := time.ParseDuration(hold)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("Invalid hold time in %q", field)
+			}
+			step.Hold = d
+		}
+		key, err := ParseKeyCode(name)
+		if err != nil {
+			return nil, err
+		}
+		step.Key = key
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// GestureRecognizer - recognizes registered gestures in a key event stream
+type GestureRecognizer struct {
+	mu       sync.Mutex
+	gestures []Gesture
+	remotes  map[LogicalAddress]*gestureState
+}
+
+// gestureState - recent presses and held back events of one remote
+type gestureState struct {
+	presses []gesturePress
+	pending []pendingKeyEvent
+	nextSeq int
+	// discard drops the remaining events of the key that completed a
+	// swallowed gesture
+	discard    bool
+	discardKey KeyCode
+}
+
+type gesturePress struct {
+	seq  int
+	key  KeyCode
+	down time.Time
+	up   time.Time
+	held time.Duration
+}
+
+type pendingKeyEvent struct {
+	seq   int
+	event KeyEvent
+}
+
+// NewGestureRecognizer - create a recognizer for the given gestures
+func NewGestureRecognizer(gestures ...Gesture) (*GestureRecognizer, error) {
+	r := &GestureRecognizer{remotes: make(map[LogicalAddress]*gestureState)}
+	for _, g := range gestures {
+		if err := r.Add(g); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Add - register a gesture, when several gestures match the first one
+// added wins
+func (r *GestureRecognizer) Add(g Gesture) error {
+	if g.Name == "" {
+		return fmt.Errorf("Gesture without name")
+	}
+	if len(g.Steps) == 0 {
+		return fmt.Errorf("Gesture %s has no steps", g.Name)
+	}
+	if g.Timeout <= 0 {
+		g.Timeout = DefaultGestureTimeout
+	}
+	g.Steps = append([]GestureStep(nil), g.Steps...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, other := range r.gestures {
+		if other.Name == g.Name {
+			return fmt.Errorf("Gesture %s already registered", g.Name)
+		}
+	}
+	r.gestures = append(r.gestures, g)
+	return nil
+}
+
+// Remove - unregister the gesture with the given name, returns false if
+// there is none
+func (r *GestureRecognizer) Remove(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, g := range r.gestures {
+		if g.Name == name {
+			r.gestures = append(r.gestures[:i], r.gestures[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Recognize - pass the events from in on, adding a GestureEvent for every
+// recognized gesture. The returned channel is closed when in is closed or
+// ctx is done
+func (r *GestureRecognizer) Recognize(ctx context.Context, in <-chan KeyEvent) <-chan KeyEvent {
+	out := make(chan KeyEvent, 16)
+
+	go func() {
+		defer close(out)
+
+		emit := func(events []KeyEvent) bool {
+			for _, ev := range events {
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			return true
+		}
+
+		for {
+			var wake <-chan time.Time
+			if next, ok := r.next(); ok {
+				wake = time.After(time.Until(next))
+			}
+
+			var events []KeyEvent
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-in:
+				if !ok {
+					emit(r.flush())
+					return
+				}
+				events = r.feed(ev)
+			case now := <-wake:
+				events = r.expire(now)
+			}
+			if !emit(events) {
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// feed - handle an event, returns the events to pass on
+func (r *GestureRecognizer) feed(ev KeyEvent) []KeyEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var info KeyInfo
+	var held time.Duration
+	switch ev := ev.(type) {
+	case KeyDownEvent:
+		info = ev.KeyInfo
+	case KeyRepeatEvent:
+		info, held = ev.KeyInfo, ev.Held
+	case LongPressEvent:
+		info, held = ev.KeyInfo, ev.Held
+	case KeyUpEvent:
+		info, held = ev.KeyInfo, ev.Held
+	case DoubleTapEvent:
+		info = ev.KeyInfo
+	default:
+		return []KeyEvent{ev}
+	}
+	if info.Vendor {
+		return []KeyEvent{ev}
+	}
+
+	s := r.remotes[info.Initiator]
+	if s == nil {
+		s = new(gestureState)
+		r.remotes[info.Initiator] = s
+	}
+
+	_, down := ev.(KeyDownEvent)
+	if s.discard && !down && info.Key == s.discardKey {
+		if _, ok := ev.(KeyUpEvent); ok {
+			s.discard = false
+		}
+		return nil
+	}
+	s.discard = false
+
+	var events []KeyEvent
+	if down {
+		if last := s.last(); last != nil && !last.up.IsZero() && info.Time.Sub(last.up) > r.timeout() {
+			events = s.flush(len(s.presses))
+			s.presses = nil
+		}
+		s.presses = append(s.presses, gesturePress{seq: s.nextSeq, key: info.Key, down: info.Time})
+		s.nextSeq++
+		if len(s.presses) > r.maxSteps() {
+			s.presses = s.presses[1:]
+		}
+	}
+
+	last := s.last()
+	if last == nil || last.key != info.Key {
+		// the press started before the gesture state
+		return append(events, ev)
+	}
+	if held > last.held {
+		last.held = held
+	}
+	if up, ok := ev.(KeyUpEvent); ok {
+		last.up = up.Time
+	}
+	s.pending = append(s.pending, pendingKeyEvent{seq: last.seq, event: ev})
+
+	if g, start, ok := r.match(s); ok {
+		if !g.Swallow {
+			events = append(events, s.flush(len(s.presses))...)
+		} else {
+			events = append(events, s.flush(start)...)
+			s.pending = nil
+			if last.up.IsZero() {
+				s.discard, s.discardKey = true, last.key
+			}
+		}
+		s.presses = nil
+		return append(events, GestureEvent{Name: g.Name, Initiator: info.Initiator, Time: info.Time})
+	}
+
+	return append(events, s.flush(r.swallowed(s))...)
+}
+
+// expire - give up on gestures whose next key did not come in time
+func (r *GestureRecognizer) expire(now time.Time) []KeyEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []KeyEvent
+	for address := TV; address <= Broadcast; address++ {
+		s := r.remotes[address]
+		if s == nil {
+			continue
+		}
+		if last := s.last(); last != nil && !last.up.IsZero() && now.Sub(last.up) > r.timeout() {
+			events = append(events, s.flush(len(s.presses))...)
+			s.presses = nil
+		}
+	}
+	return events
+}
+
+// flush - all held back events
+func (r *GestureRecognizer) flush() []KeyEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []KeyEvent
+	for address := TV; address <= Broadcast; address++ {
+		if s := r.remotes[address]; s != nil {
+			events = append(events, s.flush(len(s.presses))...)
+			s.presses = nil
+		}
+	}
+	return events
+}
+
+// next - the time at which expire has to be called next
+func (r *GestureRecognizer) next() (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var next time.Time
+	for _, s := range r.remotes {
+		last := s.last()
+		if last == nil || last.up.IsZero() {
+			continue
+		}
+		if t := last.up.Add(r.timeout() + time.Millisecond); next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// match - the first gesture matched by the most recent presses and the
+// index of its first press
+func (r *GestureRecognizer) match(s *gestureState) (Gesture, int, bool) {
+	for _, g := range r.gestures {
+		start := len(s.presses) - len(g.Steps)
+		if start >= 0 && s.matches(g, start, len(g.Steps), false) {
+			return g, start, true
+		}
+	}
+	return Gesture{}, 0, false
+}
+
+// swallowed - the index of the first press that may still become part of
+// a swallowed gesture, presses before it can be passed on
+func (r *GestureRecognizer) swallowed(s *gestureState) int {
+	for start := 0; start < len(s.presses); start++ {
+		for _, g := range r.gestures {
+			n := len(s.presses) - start
+			if g.Swallow && n <= len(g.Steps) && s.matches(g, start, n, true) {
+				return start
+			}
+		}
+	}
+	return len(s.presses)
+}
+
+// timeout - the longest inter-key timeout of all gestures
+func (r *GestureRecognizer) timeout() time.Duration {
+	timeout := DefaultGestureTimeout
+	for _, g := range r.gestures {
+		if g.Timeout > timeout {
+			timeout = g.Timeout
+		}
+	}
+	return timeout
+}
+
+func (r *GestureRecognizer) maxSteps() int {
+	n := 1
+	for _, g := range r.gestures {
+		if len(g.Steps) > n {
+			n = len(g.Steps)
+		}
+	}
+	return n
+}
+
+// matches - whether the n presses from start match the first n steps of
+// the gesture, a step that has to be held matches once it was held long
+// enough or, for a partial match, while it is still held
+func (s *gestureState) matches(g Gesture, start, n int, partial bool) bool {
+	for i := 0; i < n; i++ {
+		p, step := s.presses[start+i], g.Steps[i]
+		if p.key != step.Key {
+			return false
+		}
+		if i > 0 && p.down.Sub(s.presses[start+i-1].up) > g.Timeout {
+			return false
+		}
+		held := p.held
+		if p.up.IsZero() && i < n-1 {
+			return false
+		}
+		if !p.up.IsZero() && p.up.Sub(p.down) > held {
+			held = p.up.Sub(p.down)
+		}
+		if held < step.Hold && !(partial && p.up.IsZero()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *gestureState) last() *gesturePress {
+	if len(s.presses) == 0 {
+		return nil
+	}
+	return &s.presses[len(s.presses)-1]
+}
+
+// flush - pass on the held back events of the presses before index end
+func (s *gestureState) flush(end int) []KeyEvent {
+	seq := s.nextSeq
+	if end < len(s.presses) {
+		seq = s.presses[end].seq
+	}
+
+	var events []KeyEvent
+	kept := s.pending[:0]
+	for _, p := range s.pending {
+		if p.seq < seq {
+			events = append(events, p.event)
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	s.pending = kept
+	return events
+}
@@ -0,0 +1,142 @@
+package cec
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// keyScript - drives a key processor and a gesture recognizer
+type keyScript struct {
+	t     *testing.T
+	start time.Time
+	p     *keyProcessor
+	r     *GestureRecognizer
+	out   []KeyEvent
+}
+
+func newKeyScript(t *testing.T, gestures ...Gesture) *keyScript {
+	r, err := NewGestureRecognizer(gestures...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &keyScript{
+		t:     t,
+		start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		p:     newKeyProcessor(KeyEventOptions{LongPress: -1, DoubleTap: -1}),
+		r:     r,
+	}
+}
+
+func (s *keyScript) at(ms int) time.Time {
+	return s.start.Add(time.Duration(ms) * time.Millisecond)
+}
+
+func (s *keyScript) feed(events []KeyEvent) {
+	for _, ev := range events {
+		s.out = append(s.out, s.r.feed(ev)...)
+	}
+}
+
+// tap - press key at ms and hold it for held ms, repeating every 400ms
+func (s *keyScript) tap(ms int, key KeyCode, held int) {
+	for t := 0; t < held; t += 400 {
+		s.feed(s.p.command(command(TV, Recording1, opcodeUserControlPressed, byte(key)), s.at(ms+t)))
+	}
+	s.feed(s.p.command(command(TV, Recording1, opcodeUserControlRelease), s.at(ms+held)))
+}
+
+func (s *keyScript) expire(ms int) {
+	s.out = append(s.out, s.r.expire(s.at(ms))...)
+}
+
+func (s *keyScript) check(want ...string) {
+	s.t.Helper()
+
+	got := describe(s.out)
+	if !reflect.DeepEqual(got, want) {
+		s.t.Errorf("events =\n%q\nwant\n%q", got, want)
+	}
+	s.out = nil
+}
+
+func mustSteps(t *testing.T, s string) []GestureStep {
+	steps, err := ParseGestureSteps(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return steps
+}
+
+func TestGestureSequence(t *testing.T) {
+	s := newKeyScript(t, Gesture{Name: "rrg", Steps: mustSteps(t, "Red Red Green")})
+
+	s.tap(0, KeyRed, 100)
+	s.tap(200, KeyRed, 100)
+	s.tap(400, KeyGreen, 100)
+	s.check("down Red", "up Red 100ms false", "down Red", "up Red 100ms false",
+		"down Green", "gesture rrg", "up Green 100ms false")
+
+	// too slow
+	s.tap(1000, KeyRed, 100)
+	s.tap(1200, KeyRed, 100)
+	s.tap(3000, KeyGreen, 100)
+	s.check("down Red", "up Red 100ms false", "down Red", "up Red 100ms false",
+		"down Green", "up Green 100ms false")
+}
+
+func TestGestureHold(t *testing.T) {
+	s := newKeyScript(t, Gesture{Name: "off", Steps: mustSteps(t, "Stop:3s Power")})
+
+	s.tap(0, KeyStop, 1000)
+	s.tap(1200, KeyPower, 100)
+	s.check("down Stop", "repeat Stop 1", "repeat Stop 2", "up Stop 1s false",
+		"down Power", "up Power 100ms false")
+
+	s.tap(2000, KeyStop, 3200)
+	s.tap(5300, KeyPower, 100)
+	s.check("down Stop", "repeat Stop 1", "repeat Stop 2", "repeat Stop 3",
+		"repeat Stop 4", "repeat Stop 5", "repeat Stop 6", "repeat Stop 7",
+		"up Stop 3.2s false", "down Power", "gesture off", "up Power 100ms false")
+}
+
+func TestGestureSwallow(t *testing.T) {
+	s := newKeyScript(t, Gesture{Name: "rg", Steps: mustSteps(t, "Red Green"), Swallow: true})
+
+	s.tap(0, KeyRed, 100)
+	s.check()
+	s.tap(200, KeyGreen, 100)
+	s.check("gesture rg")
+
+	// a different key releases the held back events
+	s.tap(1000, KeyRed, 100)
+	s.tap(1200, KeyBlue, 100)
+	s.check("down Red", "up Red 100ms false", "down Blue", "up Blue 100ms false")
+
+	// so does the timeout
+	s.tap(2000, KeyRed, 100)
+	s.expire(2500)
+	s.check()
+	s.expire(3200)
+	s.check("down Red", "up Red 100ms false")
+
+	// only the presses that are part of the gesture are swallowed
+	s.tap(4000, KeyRed, 100)
+	s.tap(4200, KeyRed, 100)
+	s.tap(4400, KeyGreen, 100)
+	s.check("down Red", "up Red 100ms false", "gesture rg")
+}
+
+func TestParseGestureSteps(t *testing.T) {
+	got, err := ParseGestureSteps("Stop:3s, power")
+	want := []GestureStep{{Key: KeyStop, Hold: 3 * time.Second}, {Key: KeyPower}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseGestureSteps() = %v, %v, want %v", got, err, want)
+	}
+
+	for _, in := range []string{"", "Stop:x", "Stop:-1s", "Nope"} {
+		if _, err := ParseGestureSteps(in); err == nil {
+			t.Errorf("ParseGestureSteps(%q) succeeded", in)
+		}
+	}
+}
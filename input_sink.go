@@ -0,0 +1,270 @@
+package cec
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Linux input event types and key codes, see
+// linux/input-event-codes.h
+const (
+	evSyn     = 0x00
+	evKey     = 0x01
+	synReport = 0
+
+	linuxKeyEsc          = 1
+	linuxKey1            = 2
+	linuxKey0            = 11
+	linuxKeyBackspace    = 14
+	linuxKeyEnter        = 28
+	linuxKeyDot          = 52
+	linuxKeyF1           = 59
+	linuxKeyUp           = 103
+	linuxKeyPageUp       = 104
+	linuxKeyLeft         = 105
+	linuxKeyRight        = 106
+	linuxKeyDown         = 108
+	linuxKeyPageDown     = 109
+	linuxKeyMute         = 113
+	linuxKeyVolumeDown   = 114
+	linuxKeyVolumeUp     = 115
+	linuxKeyPower        = 116
+	linuxKeyPause        = 119
+	linuxKeyStop         = 128
+	linuxKeyHelp         = 138
+	linuxKeyMenu         = 139
+	linuxKeySetup        = 141
+	linuxKeyBack         = 158
+	linuxKeyEjectCD      = 161
+	linuxKeyNextSong     = 163
+	linuxKeyPlayPause    = 164
+	linuxKeyPreviousSong = 165
+	linuxKeyRecord       = 167
+	linuxKeyRewind       = 168
+	linuxKeyPlay         = 207
+	linuxKeyFastForward  = 208
+	linuxKeySelect       = 0x161
+	linuxKeyInfo         = 0x166
+	linuxKeyFavorites    = 0x16C
+	linuxKeyEPG          = 0x16D
+	linuxKeySubtitle     = 0x172
+	linuxKeyAngle        = 0x173
+	linuxKeyRed          = 0x18E
+	linuxKeyGreen        = 0x18F
+	linuxKeyYellow       = 0x190
+	linuxKeyBlue         = 0x191
+	linuxKeyChannelUp    = 0x192
+	linuxKeyChannelDown  = 0x193
+	linuxKeyLast         = 0x195
+	linuxKeyContextMenu  = 0x1B6
+	linuxKeyRootMenu     = 0x26A
+)
+
+// DefaultInputKeymap - Linux input key codes for the navigation, digit,
+// media and colour keys of a CEC remote
+var DefaultInputKeymap = map[KeyCode]uint16{
+	KeySelect:                    linuxKeyEnter,
+	KeyUp:                        linuxKeyUp,
+	KeyDown:                      linuxKeyDown,
+	KeyLeft:                      linuxKeyLeft,
+	KeyRight:                     linuxKeyRight,
+	KeyRootMenu:                  linuxKeyRootMenu,
+	KeySetupMenu:                 linuxKeySetup,
+	KeyContentsMenu:              linuxKeyMenu,
+	KeyFavoriteMenu:              linuxKeyFavorites,
+	KeyExit:                      linuxKeyBack,
+	KeyMediaContextSensitiveMenu: linuxKeyContextMenu,
+	Key0:                         linuxKey0,
+	Key1:                         linuxKey1,
+	Key2:                         linuxKey1 + 1,
+	Key3:                         linuxKey1 + 2,
+	Key4:                         linuxKey1 + 3,
+	Key5:                         linuxKey1 + 4,
+	Key6:                         linuxKey1 + 5,
+	Key7:                         linuxKey1 + 6,
+	Key8:                         linuxKey1 + 7,
+	Key9:                         linuxKey1 + 8,
+	KeyDot:                       linuxKeyDot,
+	KeyEnter:                     linuxKeyEnter,
+	KeyClear:                     linuxKeyBackspace,
+	KeyChannelUp:                 linuxKeyChannelUp,
+	KeyChannelDown:               linuxKeyChannelDown,
+	KeyPreviousChannel:           linuxKeyLast,
+	KeyDisplayInformation:        linuxKeyInfo,
+	KeyHelp:                      linuxKeyHelp,
+	KeyPageUp:                    linuxKeyPageUp,
+	KeyPageDown:                  linuxKeyPageDown,
+	KeyVolumeUp:                  linuxKeyVolumeUp,
+	KeyVolumeDown:                linuxKeyVolumeDown,
+	KeyMute:                      linuxKeyMute,
+	KeyPlay:                      linuxKeyPlay,
+	KeyStop:                      linuxKeyStop,
+	KeyPause:                     linuxKeyPause,
+	KeyRecord:                    linuxKeyRecord,
+	KeyRewind:                    linuxKeyRewind,
+	KeyFastForward:               linuxKeyFastForward,
+	KeyEject:                     linuxKeyEjectCD,
+	KeyForward:                   linuxKeyNextSong,
+	KeyBackward:                  linuxKeyPreviousSong,
+	KeyAngle:                     linuxKeyAngle,
+	KeySubPicture:                linuxKeySubtitle,
+	KeyElectronicProgramGuide:    linuxKeyEPG,
+	KeyPausePlay:                 linuxKeyPlayPause,
+	KeyPower:                     linuxKeyPower,
+	KeyPowerToggle:               linuxKeyPower,
+	KeyBlue:                      linuxKeyBlue,
+	KeyRed:                       linuxKeyRed,
+	KeyGreen:                     linuxKeyGreen,
+	KeyYellow:                    linuxKeyYellow,
+	KeyF5:                        linuxKeyF1 + 4,
+	KeyAnReturn:                  linuxKeyEsc,
+}
+
+// InputWriter - writes Linux input events, e.g. to a uinput device
+type InputWriter interface {
+	WriteEvent(typ uint16, code uint16, value int32) error
+	Close() error
+}
+
+// InputSink - translates key events into Linux input key events. Presses,
+// repeats and releases of mapped keys are written, other events and
+// vendor keys are ignored
+type InputSink struct {
+	mu      sync.Mutex
+	w       InputWriter
+	keymap  map[KeyCode]uint16
+	pressed map[LogicalAddress]uint16
+}
+
+// NewInputSink - create a sink writing to w, a nil keymap uses
+// DefaultInputKeymap
+func NewInputSink(w InputWriter, keymap map[KeyCode]uint16) *InputSink {
+	if keymap == nil {
+		keymap = DefaultInputKeymap
+	}
+	return &InputSink{w: w, keymap: keymap, pressed: make(map[LogicalAddress]uint16)}
+}
+
+// Handle - write the input events for a key event
+func (s *InputSink) Handle(ev KeyEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, ok := ev.keyEvent()
+	if !ok || info.Vendor {
+		return nil
+	}
+
+	switch ev.(type) {
+	case KeyDownEvent:
+		code, ok := s.keymap[info.Key]
+		if !ok {
+			return nil
+		}
+		if err := s.release(info.Initiator); err != nil {
+			return err
+		}
+		s.pressed[info.Initiator] = code
+		return s.write(code, 1)
+	case KeyRepeatEvent:
+		code, ok := s.pressed[info.Initiator]
+		if !ok || code != s.keymap[info.Key] {
+			return nil
+		}
+		return s.write(code, 2)
+	case KeyUpEvent:
+		return s.release(info.Initiator)
+	}
+	return nil
+}
+
+// Run - handle the events from in until it is closed or ctx is done,
+// returns the first write error
+func (s *InputSink) Run(ctx context.Context, in <-chan KeyEvent) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-in:
+			if !ok {
+				return nil
+			}
+			if err := s.Handle(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Close - release all pressed keys and close the writer
+func (s *InputSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for initiator := range s.pressed {
+		if er := s.release(initiator); er != nil && err == nil {
+			err = er
+		}
+	}
+	if er := s.w.Close(); er != nil && err == nil {
+		err = er
+	}
+	return err
+}
+
+// release - release the key pressed on the remote of initiator, must be
+// called with mu held
+func (s *InputSink) release(initiator LogicalAddress) error {
+	code, ok := s.pressed[initiator]
+	if !ok {
+		return nil
+	}
+	delete(s.pressed, initiator)
+	return s.write(code, 0)
+}
+
+// write - write a key event followed by a report, must be called with mu
+// held
+func (s *InputSink) write(code uint16, value int32) error {
+	if err := s.w.WriteEvent(evKey, code, value); err != nil {
+		return fmt.Errorf("Error writing input event: %w", err)
+	}
+	if err := s.w.WriteEvent(evSyn, synReport, 0); err != nil {
+		return fmt.Errorf("Error writing input event: %w", err)
+	}
+	return nil
+}
+
+// inputKeys - the distinct Linux key codes of a keymap
+func inputKeys(keymap map[KeyCode]uint16) []uint16 {
+	seen := make(map[uint16]bool)
+	var keys []uint16
+	for _, code := range keymap {
+		if !seen[code] {
+			seen[code] = true
+			keys = append(keys, code)
+		}
+	}
+	return keys
+}
+
+// reverseInputKeymap - map Linux key codes to keys, the lowest key code
+// wins when several keys share a Linux key code
+func reverseInputKeymap(keymap map[KeyCode]uint16) map[uint16]KeyCode {
+	keys := make([]KeyCode, 0, len(keymap))
+	for key := range keymap {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	reverse := make(map[uint16]KeyCode)
+	for _, key := range keys {
+		if _, ok := reverse[keymap[key]]; !ok {
+			reverse[keymap[key]] = key
+		}
+	}
+	return reverse
+}
@@ -0,0 +1,93 @@
+package cec
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type fakeInputWriter struct {
+	events []string
+	closed bool
+	err    error
+}
+
+func (w *fakeInputWriter) WriteEvent(typ uint16, code uint16, value int32) error {
+	if w.err != nil {
+		return w.err
+	}
+	if typ == evSyn {
+		w.events = append(w.events, "syn")
+	} else {
+		w.events = append(w.events, fmt.Sprintf("%d:%d", code, value))
+	}
+	return nil
+}
+
+func (w *fakeInputWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestInputSink(t *testing.T) {
+	w := new(fakeInputWriter)
+	s := NewInputSink(w, nil)
+
+	up := KeyInfo{Initiator: TV, Key: KeyUp}
+	events := []KeyEvent{
+		KeyDownEvent{KeyInfo: up},
+		KeyRepeatEvent{KeyInfo: up, Count: 1},
+		LongPressEvent{KeyInfo: up},
+		KeyUpEvent{KeyInfo: up},
+		// unmapped and vendor keys are ignored
+		KeyDownEvent{KeyInfo: KeyInfo{Initiator: TV, Key: KeyData}},
+		KeyUpEvent{KeyInfo: KeyInfo{Initiator: TV, Key: KeyData}},
+		KeyDownEvent{KeyInfo: KeyInfo{Initiator: TV, Key: KeyRed, Vendor: true}},
+		// pressed when closed
+		KeyDownEvent{KeyInfo: KeyInfo{Initiator: TV, Key: KeyBlue}},
+	}
+	for _, ev := range events {
+		if err := s.Handle(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"103:1", "syn", "103:2", "syn", "103:0", "syn", "401:1", "syn", "401:0", "syn"}
+	if !reflect.DeepEqual(w.events, want) {
+		t.Errorf("events = %q, want %q", w.events, want)
+	}
+	if !w.closed {
+		t.Error("writer not closed")
+	}
+}
+
+func TestInputSinkCustomKeymap(t *testing.T) {
+	w := new(fakeInputWriter)
+	s := NewInputSink(w, map[KeyCode]uint16{KeyRed: linuxKeyF1})
+
+	// a new key on the same remote releases the previous one
+	s.Handle(KeyDownEvent{KeyInfo: KeyInfo{Initiator: TV, Key: KeyRed}})
+	s.Handle(KeyDownEvent{KeyInfo: KeyInfo{Initiator: TV, Key: KeyRed}})
+	s.Handle(KeyDownEvent{KeyInfo: KeyInfo{Initiator: TV, Key: KeyBlue}})
+
+	want := []string{"59:1", "syn", "59:0", "syn", "59:1", "syn"}
+	if !reflect.DeepEqual(w.events, want) {
+		t.Errorf("events = %q, want %q", w.events, want)
+	}
+
+	w.err = errors.New("broken")
+	if err := s.Handle(KeyUpEvent{KeyInfo: KeyInfo{Initiator: TV, Key: KeyRed}}); !errors.Is(err, w.err) {
+		t.Errorf("Handle() = %v, want %v", err, w.err)
+	}
+}
+
+func TestInputKeys(t *testing.T) {
+	keys := inputKeys(map[KeyCode]uint16{KeyPower: linuxKeyPower, KeyPowerToggle: linuxKeyPower, KeyRed: linuxKeyRed})
+	if len(keys) != 2 {
+		t.Errorf("inputKeys() = %v, want 2 keys", keys)
+	}
+}
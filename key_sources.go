@@ -0,0 +1,345 @@
+package cec
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLircReleaseTimeout - time after the last line of a key at which
+// LircSource considers it released, lircd sends no releases
+const DefaultLircReleaseTimeout = 200 * time.Millisecond
+
+// lircAliases - lircd button names, without the KEY_ prefix, that are
+// not accepted by ParseKeyCode
+var lircAliases = map[string]KeyCode{
+	"esc":          KeyExit,
+	"home":         KeyRootMenu,
+	"rootmenu":     KeyRootMenu,
+	"setup":        KeySetupMenu,
+	"favorites":    KeyFavoriteMenu,
+	"contextmenu":  KeyMediaContextSensitiveMenu,
+	"playpause":    KeyPausePlay,
+	"nextsong":     KeyForward,
+	"previoussong": KeyBackward,
+	"stopcd":       KeyStop,
+	"ejectcd":      KeyEject,
+	"subtitle":     KeySubPicture,
+	"last":         KeyPreviousChannel,
+	"backspace":    KeyClear,
+}
+
+// LircKeyCode - the key for a lircd button name such as "KEY_UP" or
+// "ChannelUp"
+func LircKeyCode(button string) (KeyCode, error) {
+	name := button
+	if len(name) > 4 && strings.EqualFold(name[:4], "KEY_") {
+		name = name[4:]
+	}
+	if key, err := ParseKeyCode(name); err == nil {
+		return key, nil
+	}
+	if key, ok := lircAliases[strings.ToLower(removeSeparators(name))]; ok {
+		return key, nil
+	}
+	return 0, fmt.Errorf("Unknown lirc button %q", button)
+}
+
+// LircSource - reads keys from a lircd socket
+type LircSource struct {
+	conn    io.ReadCloser
+	timeout time.Duration
+	keys    chan InputKey
+	err     error
+
+	held    KeyCode
+	holding bool
+	pending *InputKey
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// DialLirc - connect to the lircd socket at path, e.g. /run/lirc/lircd
+func DialLirc(path string) (*LircSource, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return NewLircSource(conn, DefaultLircReleaseTimeout), nil
+}
+
+// NewLircSource - read keys from a lircd connection, a key is released
+// when no line repeats it within timeout. A line with repeat count 0 for
+// the held key is a new press, the held key is released first
+func NewLircSource(conn io.ReadCloser, timeout time.Duration) *LircSource {
+	if timeout <= 0 {
+		timeout = DefaultLircReleaseTimeout
+	}
+	s := &LircSource{
+		conn:    conn,
+		timeout: timeout,
+		keys:    make(chan InputKey),
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.keys)
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			// replies to commands start with BEGIN and are skipped
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 4 {
+				continue
+			}
+			repeat, err := strconv.ParseUint(fields[1], 16, 32)
+			if err != nil {
+				continue
+			}
+			key, err := LircKeyCode(fields[2])
+			if err != nil {
+				continue
+			}
+
+			state := KeyStateDown
+			if repeat > 0 {
+				state = KeyStateRepeat
+			}
+			select {
+			case s.keys <- InputKey{Key: key, State: state}:
+			case <-s.done:
+				s.err = io.EOF
+				return
+			}
+		}
+		s.err = scanner.Err()
+		if s.err == nil {
+			s.err = io.EOF
+		}
+	}()
+
+	return s
+}
+
+// ReadKey - the next key press, repeat or release
+func (s *LircSource) ReadKey(ctx context.Context) (InputKey, error) {
+	if s.pending != nil {
+		key := *s.pending
+		s.pending = nil
+		s.held, s.holding = key.Key, true
+		return key, nil
+	}
+
+	var timeout <-chan time.Time
+	if s.holding {
+		t := time.NewTimer(s.timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case <-ctx.Done():
+		return InputKey{}, ctx.Err()
+	case <-s.done:
+		return InputKey{}, io.EOF
+	case <-timeout:
+		s.holding = false
+		return InputKey{Key: s.held, State: KeyStateUp}, nil
+	case key, ok := <-s.keys:
+		if !ok {
+			select {
+			case <-s.done:
+				// reading failed because the connection was closed
+				return InputKey{}, io.EOF
+			default:
+			}
+			if s.holding {
+				s.holding = false
+				return InputKey{Key: s.held, State: KeyStateUp}, nil
+			}
+			return InputKey{}, s.err
+		}
+		if s.holding && (s.held != key.Key || key.State == KeyStateDown) {
+			// a new key, or a new press of the same one, releases the
+			// previous one
+			s.pending = &key
+			s.holding = false
+			return InputKey{Key: s.held, State: KeyStateUp}, nil
+		}
+		s.held, s.holding = key.Key, true
+		return key, nil
+	}
+}
+
+// Close - close the lircd connection and stop reading
+func (s *LircSource) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.conn.Close()
+	})
+	return err
+}
+
+// NetworkSource - reads keys from clients connecting to a listener. Each
+// line a client sends holds a key name or hex code, optionally followed
+// by "down", "up" or "repeat", a key without state is tapped. Clients get
+// "OK" or "ERROR <reason>" for every line
+type NetworkSource struct {
+	ln   net.Listener
+	keys chan InputKey
+	done chan struct{}
+
+	mu     sync.Mutex
+	conns  map[net.Conn]bool
+	closed bool
+}
+
+// ListenKeys - accept key clients on the given network address, e.g.
+// "unix", "/run/cec-keys" or "tcp", ":8765". A TCP address without a host
+// listens on loopback only, use "0.0.0.0:8765" for every interface. The
+// listener has no authentication, anyone who can connect can press keys
+func ListenKeys(network, address string) (*NetworkSource, error) {
+	if host, port, err := net.SplitHostPort(address); err == nil && host == "" {
+		switch network {
+		case "tcp", "tcp4":
+			address = net.JoinHostPort("127.0.0.1", port)
+		case "tcp6":
+			address = net.JoinHostPort("::1", port)
+		}
+	}
+
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewNetworkSource(ln), nil
+}
+
+// NewNetworkSource - accept key clients on ln
+func NewNetworkSource(ln net.Listener) *NetworkSource {
+	s := &NetworkSource{
+		ln:    ln,
+		keys:  make(chan InputKey),
+		done:  make(chan struct{}),
+		conns: make(map[net.Conn]bool),
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			if s.closed {
+				s.mu.Unlock()
+				conn.Close()
+				return
+			}
+			s.conns[conn] = true
+			s.mu.Unlock()
+
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *NetworkSource) serve(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		key, err := parseNetworkKey(line)
+		if err != nil {
+			fmt.Fprintf(conn, "ERROR %s\n", err)
+			continue
+		}
+		select {
+		case s.keys <- key:
+		case <-s.done:
+			return
+		}
+		if _, err := io.WriteString(conn, "OK\n"); err != nil {
+			return
+		}
+	}
+}
+
+func parseNetworkKey(line string) (InputKey, error) {
+	fields := strings.Fields(line)
+	if len(fields) > 2 {
+		return InputKey{}, errors.New("Expected a key and an optional state")
+	}
+
+	key, err := ParseKeyCode(fields[0])
+	if err != nil {
+		return InputKey{}, err
+	}
+	if len(fields) == 1 {
+		return InputKey{Key: key, State: KeyStateTap}, nil
+	}
+
+	switch strings.ToLower(fields[1]) {
+	case "down":
+		return InputKey{Key: key, State: KeyStateDown}, nil
+	case "up":
+		return InputKey{Key: key, State: KeyStateUp}, nil
+	case "repeat":
+		return InputKey{Key: key, State: KeyStateRepeat}, nil
+	}
+	return InputKey{}, fmt.Errorf("Unknown key state %q", fields[1])
+}
+
+// ReadKey - the next key from any client
+func (s *NetworkSource) ReadKey(ctx context.Context) (InputKey, error) {
+	select {
+	case <-ctx.Done():
+		return InputKey{}, ctx.Err()
+	case <-s.done:
+		return InputKey{}, io.EOF
+	case key := <-s.keys:
+		return key, nil
+	}
+}
+
+// Addr - the address clients connect to
+func (s *NetworkSource) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// Close - stop accepting clients and disconnect all clients
+func (s *NetworkSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return s.ln.Close()
+}
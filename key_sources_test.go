@@ -0,0 +1,162 @@
+package cec
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLircKeyCode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want KeyCode
+		ok   bool
+	}{
+		{"KEY_UP", KeyUp, true},
+		{"KEY_CHANNELUP", KeyChannelUp, true},
+		{"key_playpause", KeyPausePlay, true},
+		{"KEY_5", Key5, true},
+		{"ChannelDown", KeyChannelDown, true},
+		{"KEY_NOPE", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := LircKeyCode(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("LircKeyCode(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestLircSource(t *testing.T) {
+	r, w := io.Pipe()
+	s := NewLircSource(r, 50*time.Millisecond)
+	defer s.Close()
+
+	go func() {
+		io.WriteString(w, "BEGIN\nVERSION\nSUCCESS\nEND\n")
+		io.WriteString(w, "0000000000000067 00 KEY_UP lirc\n")
+		io.WriteString(w, "0000000000000067 01 KEY_UP lirc\n")
+		io.WriteString(w, "0000000000000069 00 KEY_LEFT lirc\n")
+		// pressed again before the release timeout
+		io.WriteString(w, "0000000000000069 00 KEY_LEFT lirc\n")
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "0000000000000001 00 KEY_UNKNOWN lirc\n")
+		io.WriteString(w, "0000000000000072 00 Red TV\n")
+		w.Close()
+	}()
+
+	want := []InputKey{
+		{Key: KeyUp, State: KeyStateDown},
+		{Key: KeyUp, State: KeyStateRepeat},
+		{Key: KeyUp, State: KeyStateUp},
+		{Key: KeyLeft, State: KeyStateDown},
+		{Key: KeyLeft, State: KeyStateUp},
+		{Key: KeyLeft, State: KeyStateDown},
+		{Key: KeyLeft, State: KeyStateUp},
+		{Key: KeyRed, State: KeyStateDown},
+		{Key: KeyRed, State: KeyStateUp},
+	}
+	for i, wantKey := range want {
+		key, err := s.ReadKey(context.Background())
+		if err != nil || key != wantKey {
+			t.Fatalf("key %d = %+v, %v, want %+v", i, key, err, wantKey)
+		}
+	}
+	if _, err := s.ReadKey(context.Background()); err != io.EOF {
+		t.Errorf("ReadKey() at end = %v, want EOF", err)
+	}
+}
+
+func TestLircSourceClose(t *testing.T) {
+	r, w := io.Pipe()
+	s := NewLircSource(r, time.Second)
+
+	// nobody reads the keys, the reader blocks handing this one over
+	go io.WriteString(w, "0000000000000067 00 KEY_UP lirc\n")
+	time.Sleep(20 * time.Millisecond)
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+	select {
+	case _, ok := <-s.keys:
+		for ok {
+			_, ok = <-s.keys
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reader still running after Close")
+	}
+	if _, err := s.ReadKey(context.Background()); err != io.EOF {
+		t.Errorf("ReadKey() after Close = %v, want EOF", err)
+	}
+}
+
+func TestListenKeysLoopback(t *testing.T) {
+	s, err := ListenKeys("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if addr := s.Addr().(*net.TCPAddr); !addr.IP.IsLoopback() {
+		t.Errorf("ListenKeys(\":0\") listens on %v, want loopback", addr)
+	}
+}
+
+func TestNetworkSource(t *testing.T) {
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "keys"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewNetworkSource(ln)
+	defer s.Close()
+
+	conn, err := net.Dial("unix", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	io.WriteString(conn, "Select\n")
+	if key, err := s.ReadKey(ctx); err != nil || key != (InputKey{Key: KeySelect, State: KeyStateTap}) {
+		t.Errorf("ReadKey() = %+v, %v", key, err)
+	}
+	if line, _ := r.ReadString('\n'); line != "OK\n" {
+		t.Errorf("reply = %q, want OK", line)
+	}
+
+	io.WriteString(conn, "volume up down\n")
+	if line, _ := r.ReadString('\n'); line[:5] != "ERROR" {
+		t.Errorf("reply = %q, want an error", line)
+	}
+
+	io.WriteString(conn, "VolumeUp down\n")
+	if key, err := s.ReadKey(ctx); err != nil || key != (InputKey{Key: KeyVolumeUp, State: KeyStateDown}) {
+		t.Errorf("ReadKey() = %+v, %v", key, err)
+	}
+
+	s.Close()
+	if _, err := s.ReadKey(ctx); err != io.EOF {
+		t.Errorf("ReadKey() after Close = %v, want EOF", err)
+	}
+}
+
+func TestReverseInputKeymap(t *testing.T) {
+	reverse := reverseInputKeymap(DefaultInputKeymap)
+	if reverse[linuxKeyEnter] != KeySelect || reverse[linuxKeyPower] != KeyPower || reverse[linuxKeyUp] != KeyUp {
+		t.Errorf("unexpected reverse keymap %v", reverse)
+	}
+}
@@ -0,0 +1,338 @@
+package cec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KeyCode - CEC user control code, the operand of USER_CONTROL_PRESSED
+type KeyCode int
+
+const (
+	KeySelect                    KeyCode = 0x00
+	KeyUp                        KeyCode = 0x01
+	KeyDown                      KeyCode = 0x02
+	KeyLeft                      KeyCode = 0x03
+	KeyRight                     KeyCode = 0x04
+	KeyRightUp                   KeyCode = 0x05
+	KeyRightDown                 KeyCode = 0x06
+	KeyLeftUp                    KeyCode = 0x07
+	KeyLeftDown                  KeyCode = 0x08
+	KeyRootMenu                  KeyCode = 0x09
+	KeySetupMenu                 KeyCode = 0x0A
+	KeyContentsMenu              KeyCode = 0x0B
+	KeyFavoriteMenu              KeyCode = 0x0C
+	KeyExit                      KeyCode = 0x0D
+	KeyMediaTopMenu              KeyCode = 0x10
+	KeyMediaContextSensitiveMenu KeyCode = 0x11
+	KeyNumberEntryMode           KeyCode = 0x1D
+	KeyNumber11                  KeyCode = 0x1E
+	KeyNumber12                  KeyCode = 0x1F
+	Key0                         KeyCode = 0x20
+	Key1                         KeyCode = 0x21
+	Key2                         KeyCode = 0x22
+	Key3                         KeyCode = 0x23
+	Key4                         KeyCode = 0x24
+	Key5                         KeyCode = 0x25
+	Key6                         KeyCode = 0x26
+	Key7                         KeyCode = 0x27
+	Key8                         KeyCode = 0x28
+	Key9                         KeyCode = 0x29
+	KeyDot                       KeyCode = 0x2A
+	KeyEnter                     KeyCode = 0x2B
+	KeyClear                     KeyCode = 0x2C
+	KeyNextFavorite              KeyCode = 0x2F
+	KeyChannelUp                 KeyCode = 0x30
+	KeyChannelDown               KeyCode = 0x31
+	KeyPreviousChannel           KeyCode = 0x32
+	KeySoundSelect               KeyCode = 0x33
+	KeyInputSelect               KeyCode = 0x34
+	KeyDisplayInformation        KeyCode = 0x35
+	KeyHelp                      KeyCode = 0x36
+	KeyPageUp                    KeyCode = 0x37
+	KeyPageDown                  KeyCode = 0x38
+	KeyPower                     KeyCode = 0x40
+	KeyVolumeUp                  KeyCode = 0x41
+	KeyVolumeDown                KeyCode = 0x42
+	KeyMute                      KeyCode = 0x43
+	KeyPlay                      KeyCode = 0x44
+	KeyStop                      KeyCode = 0x45
+	KeyPause                     KeyCode = 0x46
+	KeyRecord                    KeyCode = 0x47
+	KeyRewind                    KeyCode = 0x48
+	KeyFastForward               KeyCode = 0x49
+	KeyEject                     KeyCode = 0x4A
+	KeyForward                   KeyCode = 0x4B
+	KeyBackward                  KeyCode = 0x4C
+	KeyStopRecord                KeyCode = 0x4D
+	KeyPauseRecord               KeyCode = 0x4E
+	KeyAngle                     KeyCode = 0x50
+	KeySubPicture                KeyCode = 0x51
+	KeyVideoOnDemand             KeyCode = 0x52
+	KeyElectronicProgramGuide    KeyCode = 0x53
+	KeyTimerProgramming          KeyCode = 0x54
+	KeyInitialConfiguration      KeyCode = 0x55
+	KeySelectBroadcastType       KeyCode = 0x56
+	KeySelectSoundPresentation   KeyCode = 0x57
+	KeyAudioDescription          KeyCode = 0x58
+	KeyInternet                  KeyCode = 0x59
+	Key3DMode                    KeyCode = 0x5A
+	KeyPlayFunction              KeyCode = 0x60
+	KeyPausePlay                 KeyCode = 0x61
+	KeyRecordFunction            KeyCode = 0x62
+	KeyPauseRecordFunction       KeyCode = 0x63
+	KeyStopFunction              KeyCode = 0x64
+	KeyMuteFunction              KeyCode = 0x65
+	KeyRestoreVolume             KeyCode = 0x66
+	KeyTune                      KeyCode = 0x67
+	KeySelectMedia               KeyCode = 0x68
+	KeySelectAvInput             KeyCode = 0x69
+	KeySelectAudioInput          KeyCode = 0x6A
+	KeyPowerToggle               KeyCode = 0x6B
+	KeyPowerOff                  KeyCode = 0x6C
+	KeyPowerOn                   KeyCode = 0x6D
+	KeyBlue                      KeyCode = 0x71
+	KeyRed                       KeyCode = 0x72
+	KeyGreen                     KeyCode = 0x73
+	KeyYellow                    KeyCode = 0x74
+	KeyF5                        KeyCode = 0x75
+	KeyData                      KeyCode = 0x76
+	KeyAnReturn                  KeyCode = 0x91
+	KeyAnChannelsList            KeyCode = 0x96
+)
+
+// keyNames - unique name of each key, reserved codes have no name and are
+// written as hex
+var keyNames = map[KeyCode]string{
+	KeySelect:                    "Select",
+	KeyUp:                        "Up",
+	KeyDown:                      "Down",
+	KeyLeft:                      "Left",
+	KeyRight:                     "Right",
+	KeyRightUp:                   "RightUp",
+	KeyRightDown:                 "RightDown",
+	KeyLeftUp:                    "LeftUp",
+	KeyLeftDown:                  "LeftDown",
+	KeyRootMenu:                  "RootMenu",
+	KeySetupMenu:                 "SetupMenu",
+	KeyContentsMenu:              "ContentsMenu",
+	KeyFavoriteMenu:              "FavoriteMenu",
+	KeyExit:                      "Exit",
+	KeyMediaTopMenu:              "MediaTopMenu",
+	KeyMediaContextSensitiveMenu: "MediaContextSensitiveMenu",
+	KeyNumberEntryMode:           "NumberEntryMode",
+	KeyNumber11:                  "Number11",
+	KeyNumber12:                  "Number12",
+	Key0:                         "0",
+	Key1:                         "1",
+	Key2:                         "2",
+	Key3:                         "3",
+	Key4:                         "4",
+	Key5:                         "5",
+	Key6:                         "6",
+	Key7:                         "7",
+	Key8:                         "8",
+	Key9:                         "9",
+	KeyDot:                       "Dot",
+	KeyEnter:                     "Enter",
+	KeyClear:                     "Clear",
+	KeyNextFavorite:              "NextFavorite",
+	KeyChannelUp:                 "ChannelUp",
+	KeyChannelDown:               "ChannelDown",
+	KeyPreviousChannel:           "PreviousChannel",
+	KeySoundSelect:               "SoundSelect",
+	KeyInputSelect:               "InputSelect",
+	KeyDisplayInformation:        "DisplayInformation",
+	KeyHelp:                      "Help",
+	KeyPageUp:                    "PageUp",
+	KeyPageDown:                  "PageDown",
+	KeyPower:                     "Power",
+	KeyVolumeUp:                  "VolumeUp",
+	KeyVolumeDown:                "VolumeDown",
+	KeyMute:                      "Mute",
+	KeyPlay:                      "Play",
+	KeyStop:                      "Stop",
+	KeyPause:                     "Pause",
+	KeyRecord:                    "Record",
+	KeyRewind:                    "Rewind",
+	KeyFastForward:               "FastForward",
+	KeyEject:                     "Eject",
+	KeyForward:                   "Forward",
+	KeyBackward:                  "Backward",
+	KeyStopRecord:                "StopRecord",
+	KeyPauseRecord:               "PauseRecord",
+	KeyAngle:                     "Angle",
+	KeySubPicture:                "SubPicture",
+	KeyVideoOnDemand:             "VideoOnDemand",
+	KeyElectronicProgramGuide:    "ElectronicProgramGuide",
+	KeyTimerProgramming:          "TimerProgramming",
+	KeyInitialConfiguration:      "InitialConfiguration",
+	KeySelectBroadcastType:       "SelectBroadcastType",
+	KeySelectSoundPresentation:   "SelectSoundPresentation",
+	KeyAudioDescription:          "AudioDescription",
+	KeyInternet:                  "Internet",
+	Key3DMode:                    "3DMode",
+	KeyPlayFunction:              "PlayFunction",
+	KeyPausePlay:                 "PausePlay",
+	KeyRecordFunction:            "RecordFunction",
+	KeyPauseRecordFunction:       "PauseRecordFunction",
+	KeyStopFunction:              "StopFunction",
+	KeyMuteFunction:              "MuteFunction",
+	KeyRestoreVolume:             "RestoreVolume",
+	KeyTune:                      "Tune",
+	KeySelectMedia:               "SelectMedia",
+	KeySelectAvInput:             "SelectAvInput",
+	KeySelectAudioInput:          "SelectAudioInput",
+	KeyPowerToggle:               "PowerToggle",
+	KeyPowerOff:                  "PowerOff",
+	KeyPowerOn:                   "PowerOn",
+	KeyBlue:                      "Blue",
+	KeyRed:                       "Red",
+	KeyGreen:                     "Green",
+	KeyYellow:                    "Yellow",
+	KeyF5:                        "F5",
+	KeyData:                      "Data",
+	KeyAnReturn:                  "AnReturn",
+	KeyAnChannelsList:            "AnChannelsList",
+}
+
+// keyAliases - additional names accepted by ParseKeyCode, lower case
+// without separators
+var keyAliases = map[string]KeyCode{
+	"ok": KeySelect, "back": KeyExit, "return": KeyExit, "menu": KeyRootMenu,
+	"guide": KeyElectronicProgramGuide, "epg": KeyElectronicProgramGuide,
+	"info": KeyDisplayInformation, "skipforward": KeyForward,
+	"next": KeyForward, "skipbackward": KeyBackward, "previous": KeyBackward,
+	"f1": KeyBlue, "f2": KeyRed, "f3": KeyGreen, "f4": KeyYellow,
+	"selectavinputfunction":    KeySelectAvInput,
+	"selectaudioinputfunction": KeySelectAudioInput,
+	"selectmediafunction":      KeySelectMedia, "tunefunction": KeyTune,
+}
+
+func (k KeyCode) String() string {
+	if name, ok := keyNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02X", int(k))
+}
+
+// ParseKeyCode - parse a key given by name ("Select", "channel up",
+// "F1", ...) or as a hex code ("0x44"), ignoring case and separators
+func ParseKeyCode(name string) (KeyCode, error) {
+	key := strings.ToLower(removeSeparators(strings.TrimSpace(name)))
+	if key == "" {
+		return 0, fmt.Errorf("Empty key name")
+	}
+
+	if strings.HasPrefix(key, "0x") {
+		code, err := strconv.ParseUint(key[2:], 16, 8)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid key code %q", name)
+		}
+		return KeyCode(code), nil
+	}
+
+	for code, keyName := range keyNames {
+		if strings.ToLower(keyName) == key {
+			return code, nil
+		}
+	}
+	if code, ok := keyAliases[key]; ok {
+		return code, nil
+	}
+
+	return 0, fmt.Errorf("Unknown key %q", name)
+}
+
+// MarshalText - encode the key by name
+func (k KeyCode) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText - decode a key in any form accepted by ParseKeyCode
+func (k *KeyCode) UnmarshalText(text []byte) error {
+	code, err := ParseKeyCode(string(text))
+	if err != nil {
+		return err
+	}
+	*k = code
+	return nil
+}
+
+// PlayMode - operand of the Play Function key
+type PlayMode byte
+
+const (
+	PlayFastForwardMin    PlayMode = 0x05
+	PlayFastForwardMedium PlayMode = 0x06
+	PlayFastForwardMax    PlayMode = 0x07
+	PlayFastReverseMin    PlayMode = 0x09
+	PlayFastReverseMedium PlayMode = 0x0A
+	PlayFastReverseMax    PlayMode = 0x0B
+	PlaySlowForwardMin    PlayMode = 0x15
+	PlaySlowForwardMedium PlayMode = 0x16
+	PlaySlowForwardMax    PlayMode = 0x17
+	PlaySlowReverseMin    PlayMode = 0x19
+	PlaySlowReverseMedium PlayMode = 0x1A
+	PlaySlowReverseMax    PlayMode = 0x1B
+	PlayReverse           PlayMode = 0x20
+	PlayForward           PlayMode = 0x24
+	PlayStill             PlayMode = 0x25
+)
+
+// ChannelID - operand of the Tune Function key. A Major of 0 selects the
+// one-part channel number Minor, otherwise the two-part number
+// Major.Minor is used
+type ChannelID struct {
+	Major int
+	Minor int
+}
+
+// UICommand - a key with the operands sent along with it
+type UICommand struct {
+	Key      KeyCode
+	Operands []byte
+}
+
+// PlayFunctionCommand - play in the given mode
+func PlayFunctionCommand(mode PlayMode) UICommand {
+	return UICommand{Key: KeyPlayFunction, Operands: []byte{byte(mode)}}
+}
+
+// TuneFunctionCommand - tune to the given channel
+func TuneFunctionCommand(channel ChannelID) (UICommand, error) {
+	if channel.Major < 0 || channel.Major > 0x3FF || channel.Minor < 0 || channel.Minor > 0xFFFF {
+		return UICommand{}, fmt.Errorf("Invalid channel %d.%d", channel.Major, channel.Minor)
+	}
+
+	// 6 bit number format, 10 bit major and 16 bit minor channel number
+	format := 0x02
+	if channel.Major == 0 {
+		format = 0x01
+	}
+	id := uint32(format)<<26 | uint32(channel.Major)<<16 | uint32(channel.Minor)
+	return UICommand{Key: KeyTune, Operands: []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}}, nil
+}
+
+// SelectMediaCommand - select the media numbered 1-255
+func SelectMediaCommand(n int) (UICommand, error) {
+	return numberedCommand(KeySelectMedia, n)
+}
+
+// SelectAvInputCommand - select the A/V input numbered 1-255
+func SelectAvInputCommand(n int) (UICommand, error) {
+	return numberedCommand(KeySelectAvInput, n)
+}
+
+// SelectAudioInputCommand - select the audio input numbered 1-255
+func SelectAudioInputCommand(n int) (UICommand, error) {
+	return numberedCommand(KeySelectAudioInput, n)
+}
+
+func numberedCommand(key KeyCode, n int) (UICommand, error) {
+	if n < 1 || n > 255 {
+		return UICommand{}, fmt.Errorf("Invalid %s number %d", key, n)
+	}
+	return UICommand{Key: key, Operands: []byte{byte(n)}}, nil
+}
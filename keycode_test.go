@@ -0,0 +1,95 @@
+package cec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseKeyCode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want KeyCode
+		ok   bool
+	}{
+		{"Select", KeySelect, true},
+		{"channel up", KeyChannelUp, true},
+		{"Channel_Down", KeyChannelDown, true},
+		{"mute", KeyMute, true},
+		{"MuteFunction", KeyMuteFunction, true},
+		{"5", Key5, true},
+		{"0x44", KeyPlay, true},
+		{"0x1E", KeyNumber11, true},
+		{"OK", KeySelect, true},
+		{"back", KeyExit, true},
+		{"F1", KeyBlue, true},
+		{"3D Mode", Key3DMode, true},
+		{"", 0, false},
+		{"0x", 0, false},
+		{"0x100", 0, false},
+		{"x", 0, false},
+		{"Max", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseKeyCode(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseKeyCode(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestKeyCodeString(t *testing.T) {
+	seen := make(map[string]KeyCode)
+	for code := KeyCode(0); code <= 0xFF; code++ {
+		name := code.String()
+		if other, ok := seen[name]; ok {
+			t.Errorf("%#x and %#x are both named %q", other, code, name)
+		}
+		seen[name] = code
+
+		got, err := ParseKeyCode(name)
+		if err != nil || got != code {
+			t.Errorf("ParseKeyCode(%q) = %v, %v, want %#x", name, got, err, code)
+		}
+	}
+
+	if got := KeyCode(0x0E).String(); got != "0x0E" {
+		t.Errorf("reserved key = %q, want 0x0E", got)
+	}
+}
+
+func TestUICommands(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  UICommand
+		key  KeyCode
+		want []byte
+	}{
+		{"play", PlayFunctionCommand(PlayStill), KeyPlayFunction, []byte{0x25}},
+		{"media", must(SelectMediaCommand(3)), KeySelectMedia, []byte{3}},
+		{"av input", must(SelectAvInputCommand(1)), KeySelectAvInput, []byte{1}},
+		{"audio input", must(SelectAudioInputCommand(255)), KeySelectAudioInput, []byte{255}},
+		{"one part", must(TuneFunctionCommand(ChannelID{Minor: 42})), KeyTune, []byte{0x04, 0x00, 0x00, 0x2A}},
+		{"two part", must(TuneFunctionCommand(ChannelID{Major: 5, Minor: 1})), KeyTune, []byte{0x08, 0x05, 0x00, 0x01}},
+	}
+
+	for _, tt := range tests {
+		if tt.cmd.Key != tt.key || !bytes.Equal(tt.cmd.Operands, tt.want) {
+			t.Errorf("%s: got %v % X, want %v % X", tt.name, tt.cmd.Key, tt.cmd.Operands, tt.key, tt.want)
+		}
+	}
+
+	if _, err := SelectMediaCommand(0); err == nil {
+		t.Error("SelectMediaCommand(0) succeeded")
+	}
+	if _, err := TuneFunctionCommand(ChannelID{Major: 0x400}); err == nil {
+		t.Error("TuneFunctionCommand with major 0x400 succeeded")
+	}
+}
+
+func must(cmd UICommand, err error) UICommand {
+	if err != nil {
+		panic(err)
+	}
+	return cmd
+}
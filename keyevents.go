@@ -0,0 +1,465 @@
+package cec
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// KeyEvent - KeyDownEvent, KeyRepeatEvent, KeyUpEvent, LongPressEvent or
+// DoubleTapEvent sent by KeyEvents
+type KeyEvent interface {
+	// keyEvent - the key of the event, false for events that are not
+	// about a single key and only have Initiator and Time set
+	keyEvent() (KeyInfo, bool)
+}
+
+// KeyInfo - the key and the device it was pressed on
+type KeyInfo struct {
+	// Initiator is Unregistered for keys only reported by libcec's key
+	// press callback, such as vendor buttons libcec translates itself
+	Initiator LogicalAddress
+	Key       KeyCode
+	// Vendor is set for VENDOR_REMOTE_BUTTON_DOWN, Key then holds the
+	// first byte of the vendor specific code
+	Vendor bool
+	// Operands holds the parameters following the key code
+	Operands []byte
+	Time     time.Time
+}
+
+// KeyDownEvent - a key was pressed
+type KeyDownEvent struct {
+	KeyInfo
+}
+
+// KeyRepeatEvent - a held key was repeated by the remote, Count starts at 1
+type KeyRepeatEvent struct {
+	KeyInfo
+	Count int
+	Held  time.Duration
+}
+
+// KeyUpEvent - a key was released, TimedOut is set when the release was
+// assumed because no repeat arrived in time
+type KeyUpEvent struct {
+	KeyInfo
+	Held     time.Duration
+	TimedOut bool
+}
+
+// LongPressEvent - a key has been held for the long press threshold, sent
+// once per press before its KeyUpEvent
+type LongPressEvent struct {
+	KeyInfo
+	Held time.Duration
+}
+
+// DoubleTapEvent - a key was pressed again shortly after it was released,
+// sent after the second KeyDownEvent
+type DoubleTapEvent struct {
+	KeyInfo
+}
+
+func (ev KeyDownEvent) keyEvent() (KeyInfo, bool)   { return ev.KeyInfo, true }
+func (ev KeyRepeatEvent) keyEvent() (KeyInfo, bool) { return ev.KeyInfo, true }
+func (ev KeyUpEvent) keyEvent() (KeyInfo, bool)     { return ev.KeyInfo, true }
+func (ev LongPressEvent) keyEvent() (KeyInfo, bool) { return ev.KeyInfo, true }
+func (ev DoubleTapEvent) keyEvent() (KeyInfo, bool) { return ev.KeyInfo, true }
+
+// KeyEventOptions - thresholds used by KeyEvents
+type KeyEventOptions struct {
+	// LongPress is the time a key must be held for a LongPressEvent,
+	// negative disables long presses
+	LongPress time.Duration
+	// DoubleTap is the maximum time between a release and the next press
+	// of the same key for a DoubleTapEvent, negative disables double taps
+	DoubleTap time.Duration
+	// ReleaseTimeout is the time without repeats after which a held key
+	// is considered released
+	ReleaseTimeout time.Duration
+}
+
+// DefaultKeyEventOptions - used for fields left 0 in KeyEventOptions, the
+// release timeout is the follower safety timeout of the CEC spec
+var DefaultKeyEventOptions = KeyEventOptions{
+	LongPress:      time.Second,
+	DoubleTap:      300 * time.Millisecond,
+	ReleaseTimeout: 550 * time.Millisecond,
+}
+
+type heldKey struct {
+	info    KeyInfo
+	pressed time.Time
+	last    time.Time
+	repeats int
+	long    bool
+	doubled bool
+}
+
+// pendingKey - a key from libcec's key press callback, held back for
+// keyDedupeWindow in case the message it came from is still on its way
+type pendingKey struct {
+	key      KeyCode
+	duration time.Duration
+	at       time.Time
+}
+
+// time within which a key from libcec's key press callback and a key
+// message are taken to be the same press, libcec reports both
+const keyDedupeWindow = 100 * time.Millisecond
+
+type lastTap struct {
+	key    KeyCode
+	vendor bool
+	up     time.Time
+}
+
+// keyProcessor - turns key presses and releases into key events, all
+// times come from the caller
+type keyProcessor struct {
+	opts KeyEventOptions
+	held map[LogicalAddress]*heldKey
+	taps map[LogicalAddress]lastTap
+
+	// raw is the time of the last message about each key, rawVendor of
+	// the last vendor button whose libcec key code is unknown
+	raw       map[KeyCode]time.Time
+	rawVendor time.Time
+	pending   []pendingKey
+}
+
+func newKeyProcessor(opts KeyEventOptions) *keyProcessor {
+	if opts.LongPress == 0 {
+		opts.LongPress = DefaultKeyEventOptions.LongPress
+	}
+	if opts.DoubleTap == 0 {
+		opts.DoubleTap = DefaultKeyEventOptions.DoubleTap
+	}
+	if opts.ReleaseTimeout <= 0 {
+		opts.ReleaseTimeout = DefaultKeyEventOptions.ReleaseTimeout
+	}
+
+	return &keyProcessor{
+		opts: opts,
+		held: make(map[LogicalAddress]*heldKey),
+		taps: make(map[LogicalAddress]lastTap),
+		raw:  make(map[KeyCode]time.Time),
+	}
+}
+
+// KeyEvents - report key presses received from other devices until ctx is
+// done or the connection is closed, then the returned channel is closed.
+// Both USER_CONTROL_PRESSED/RELEASE and VENDOR_REMOTE_BUTTON_DOWN/UP are
+// handled, as are keys libcec reports through its key press callback
+// without a message, the ones that duplicate a message are dropped
+func (c *Connection) KeyEvents(ctx context.Context, opts KeyEventOptions) <-chan KeyEvent {
+	p := newKeyProcessor(opts)
+	events := make(chan KeyEvent, 16)
+
+	keys := make(chan *Command, 64)
+	remove := c.listen(func(msg *Command) {
+		if !isKeyCommand(msg) {
+			return
+		}
+		select {
+		case keys <- msg:
+		default:
+			slog.Warn("Dropping key command", "initiator", msg.Initiator, "opcode", msg.Operation)
+		}
+	})
+
+	presses := make(chan KeyPress, 64)
+	removePresses := c.listenKeyPresses(func(k *KeyPress) {
+		select {
+		case presses <- *k:
+		default:
+			slog.Warn("Dropping key press", "key", k.KeyCode)
+		}
+	})
+
+	go func() {
+		defer close(events)
+		defer remove()
+		defer removePresses()
+
+		emit := func(evs []KeyEvent) bool {
+			for _, ev := range evs {
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+					return false
+				case <-c.done:
+					return false
+				}
+			}
+			return true
+		}
+
+		for {
+			var wake <-chan time.Time
+			if next, ok := p.next(); ok {
+				wake = time.After(time.Until(next))
+			}
+
+			var evs []KeyEvent
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.done:
+				return
+			case msg := <-keys:
+				evs = p.command(msg, time.Now())
+			case k := <-presses:
+				evs = p.keyPress(k, time.Now())
+			case now := <-wake:
+				evs = p.expire(now)
+			}
+			if !emit(evs) {
+				return
+			}
+		}
+	}()
+
+	return events
+}
+
+func isKeyCommand(msg *Command) bool {
+	switch msg.Opcode {
+	case opcodeUserControlPressed, opcodeUserControlRelease,
+		opcodeVendorRemoteButtonDown, opcodeVendorRemoteButtonUp:
+		return true
+	}
+	return false
+}
+
+// command - handle a received key command
+func (p *keyProcessor) command(msg *Command, now time.Time) []KeyEvent {
+	switch msg.Opcode {
+	case opcodeUserControlPressed, opcodeVendorRemoteButtonDown:
+		params := msg.Parameters.Bytes()
+		if len(params) == 0 {
+			return nil
+		}
+		if msg.Opcode == opcodeVendorRemoteButtonDown {
+			p.seenVendor(now)
+		} else {
+			p.seen(KeyCode(params[0]), now)
+		}
+		return p.press(KeyInfo{
+			Initiator: msg.Initiator,
+			Key:       KeyCode(params[0]),
+			Vendor:    msg.Opcode == opcodeVendorRemoteButtonDown,
+			Operands:  append([]byte(nil), params[1:]...),
+			Time:      now,
+		})
+	case opcodeUserControlRelease, opcodeVendorRemoteButtonUp:
+		if h := p.held[msg.Initiator]; h != nil && h.info.Vendor {
+			p.seenVendor(now)
+		} else if h != nil {
+			p.seen(h.info.Key, now)
+		}
+		return p.release(msg.Initiator, now, false)
+	}
+	return nil
+}
+
+// seen - a message about key arrived, libcec reports it again
+func (p *keyProcessor) seen(key KeyCode, now time.Time) {
+	p.raw[key] = now
+	p.dropPending(func(k pendingKey) bool { return k.key == key })
+}
+
+// seenVendor - a vendor button message arrived, libcec may report it as
+// any key
+func (p *keyProcessor) seenVendor(now time.Time) {
+	p.rawVendor = now
+	p.dropPending(func(k pendingKey) bool { return now.Sub(k.at) <= keyDedupeWindow })
+}
+
+func (p *keyProcessor) dropPending(drop func(pendingKey) bool) {
+	kept := p.pending[:0]
+	for _, k := range p.pending {
+		if !drop(k) {
+			kept = append(kept, k)
+		}
+	}
+	p.pending = kept
+}
+
+// keyPress - handle a key from libcec's key press callback, a duration of
+// 0 is a press, otherwise the key was released after duration ms. Keys
+// that follow a message about them are dropped, a release of a key held
+// through messages releases it, as libcec reports a release of its own
+// when the release message doesn't arrive. Other keys are held back for
+// keyDedupeWindow as the message may still arrive
+func (p *keyProcessor) keyPress(k KeyPress, now time.Time) []KeyEvent {
+	if t, ok := p.raw[k.KeyCode]; ok && now.Sub(t) <= keyDedupeWindow {
+		return nil
+	}
+	if now.Sub(p.rawVendor) <= keyDedupeWindow {
+		return nil
+	}
+
+	if k.Duration > 0 {
+		if initiator, ok := p.heldBy(k.KeyCode); ok {
+			return p.release(initiator, now, false)
+		}
+		// the key was released by a message already
+		if t, ok := p.raw[k.KeyCode]; ok && now.Sub(t) <= p.opts.ReleaseTimeout {
+			return nil
+		}
+		if now.Sub(p.rawVendor) <= p.opts.ReleaseTimeout {
+			return nil
+		}
+	}
+
+	p.pending = append(p.pending, pendingKey{
+		key:      k.KeyCode,
+		duration: time.Duration(k.Duration) * time.Millisecond,
+		at:       now,
+	})
+	return nil
+}
+
+// heldBy - the initiator holding key through messages, vendor buttons
+// match any key as libcec translates them itself
+func (p *keyProcessor) heldBy(key KeyCode) (LogicalAddress, bool) {
+	for address := TV; address < Broadcast; address++ {
+		if h := p.held[address]; h != nil && (h.info.Vendor || h.info.Key == key) {
+			return address, true
+		}
+	}
+	return Unregistered, false
+}
+
+// callbackKey - a key from libcec's key press callback that no message
+// duplicated, reported for the Unregistered initiator
+func (p *keyProcessor) callbackKey(k pendingKey) []KeyEvent {
+	if k.duration == 0 {
+		return p.press(KeyInfo{Initiator: Unregistered, Key: k.key, Time: k.at})
+	}
+
+	// libcec may only report the release
+	var events []KeyEvent
+	h := p.held[Unregistered]
+	if h == nil || h.info.Key != k.key {
+		events = p.press(KeyInfo{Initiator: Unregistered, Key: k.key, Time: k.at.Add(-k.duration)})
+		h = p.held[Unregistered]
+	}
+	h.pressed = k.at.Add(-k.duration)
+	return append(events, p.release(Unregistered, k.at, false)...)
+}
+
+// press - a key press or a repeat of the held key
+func (p *keyProcessor) press(info KeyInfo) []KeyEvent {
+	now := info.Time
+
+	h := p.held[info.Initiator]
+	if h != nil && h.info.Key == info.Key && h.info.Vendor == info.Vendor {
+		h.last = now
+		h.repeats++
+
+		repeat := h.info
+		repeat.Time = now
+		events := []KeyEvent{KeyRepeatEvent{KeyInfo: repeat, Count: h.repeats, Held: now.Sub(h.pressed)}}
+		return append(events, p.longPress(h, now)...)
+	}
+
+	var events []KeyEvent
+	if h != nil {
+		// a new key implies the release of the previous one
+		events = p.release(info.Initiator, now, false)
+	}
+
+	h = &heldKey{info: info, pressed: now, last: now}
+	p.held[info.Initiator] = h
+	events = append(events, KeyDownEvent{KeyInfo: info})
+
+	tap, ok := p.taps[info.Initiator]
+	if ok && p.opts.DoubleTap > 0 && tap.key == info.Key && tap.vendor == info.Vendor && now.Sub(tap.up) <= p.opts.DoubleTap {
+		h.doubled = true
+		events = append(events, DoubleTapEvent{KeyInfo: info})
+	}
+	delete(p.taps, info.Initiator)
+
+	return events
+}
+
+// release - release the key held by initiator
+func (p *keyProcessor) release(initiator LogicalAddress, now time.Time, timedOut bool) []KeyEvent {
+	h := p.held[initiator]
+	if h == nil {
+		return nil
+	}
+	delete(p.held, initiator)
+
+	events := p.longPress(h, now)
+
+	up := h.info
+	up.Time = now
+	events = append(events, KeyUpEvent{KeyInfo: up, Held: now.Sub(h.pressed), TimedOut: timedOut})
+
+	if !h.long && !h.doubled {
+		p.taps[initiator] = lastTap{key: h.info.Key, vendor: h.info.Vendor, up: now}
+	}
+	return events
+}
+
+// longPress - report a long press once the key has been held long enough
+func (p *keyProcessor) longPress(h *heldKey, now time.Time) []KeyEvent {
+	if h.long || p.opts.LongPress < 0 || now.Sub(h.pressed) < p.opts.LongPress {
+		return nil
+	}
+	h.long = true
+
+	info := h.info
+	info.Time = now
+	return []KeyEvent{LongPressEvent{KeyInfo: info, Held: now.Sub(h.pressed)}}
+}
+
+// expire - report long presses and release keys that are no longer
+// repeated
+func (p *keyProcessor) expire(now time.Time) []KeyEvent {
+	var events []KeyEvent
+	for len(p.pending) > 0 && !p.pending[0].at.Add(keyDedupeWindow).After(now) {
+		k := p.pending[0]
+		p.pending = p.pending[1:]
+		events = append(events, p.callbackKey(k)...)
+	}
+	for address := TV; address <= Broadcast; address++ {
+		h := p.held[address]
+		if h == nil {
+			continue
+		}
+		if now.Sub(h.last) >= p.opts.ReleaseTimeout {
+			events = append(events, p.release(address, h.last.Add(p.opts.ReleaseTimeout), true)...)
+		} else {
+			events = append(events, p.longPress(h, now)...)
+		}
+	}
+	return events
+}
+
+// next - the time at which expire has to be called next, false when no
+// key is held
+func (p *keyProcessor) next() (time.Time, bool) {
+	var next time.Time
+	for _, h := range p.held {
+		t := h.last.Add(p.opts.ReleaseTimeout)
+		if !h.long && p.opts.LongPress >= 0 {
+			if long := h.pressed.Add(p.opts.LongPress); long.Before(t) {
+				t = long
+			}
+		}
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	if len(p.pending) > 0 {
+		if t := p.pending[0].at.Add(keyDedupeWindow); next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	return next, !next.IsZero()
+}
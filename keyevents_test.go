@@ -0,0 +1,233 @@
+package cec
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// describe - short form of key events for comparisons
+func describe(events []KeyEvent) []string {
+	var out []string
+	for _, ev := range events {
+		switch ev := ev.(type) {
+		case KeyDownEvent:
+			out = append(out, fmt.Sprintf("down %s", ev.Key))
+		case KeyRepeatEvent:
+			out = append(out, fmt.Sprintf("repeat %s %d", ev.Key, ev.Count))
+		case KeyUpEvent:
+			out = append(out, fmt.Sprintf("up %s %s %t", ev.Key, ev.Held, ev.TimedOut))
+		case LongPressEvent:
+			out = append(out, fmt.Sprintf("long %s", ev.Key))
+		case DoubleTapEvent:
+			out = append(out, fmt.Sprintf("double %s", ev.Key))
+		case GestureEvent:
+			out = append(out, fmt.Sprintf("gesture %s", ev.Name))
+		case NumberEnteredEvent:
+			out = append(out, fmt.Sprintf("number %s", ev.Text))
+		default:
+			out = append(out, fmt.Sprintf("%T", ev))
+		}
+	}
+	return out
+}
+
+func TestKeyProcessor(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	p := newKeyProcessor(KeyEventOptions{})
+	var events []KeyEvent
+	press := func(ms int, key KeyCode) {
+		events = append(events, p.command(command(TV, Recording1, opcodeUserControlPressed, byte(key)), at(ms))...)
+	}
+	release := func(ms int) {
+		events = append(events, p.command(command(TV, Recording1, opcodeUserControlRelease), at(ms))...)
+	}
+
+	// tap, tap again quickly
+	press(0, KeySelect)
+	release(100)
+	press(200, KeySelect)
+	release(300)
+	// held with repeats until a long press
+	press(1000, KeyUp)
+	press(1400, KeyUp)
+	press(1800, KeyUp)
+	press(2200, KeyUp)
+	release(2300)
+	// another key replaces the held one
+	press(3000, KeyLeft)
+	press(3100, KeyRight)
+	release(3200)
+
+	want := []string{
+		"down Select", "up Select 100ms false",
+		"down Select", "double Select", "up Select 100ms false",
+		"down Up", "repeat Up 1", "repeat Up 2", "repeat Up 3", "long Up", "up Up 1.3s false",
+		"down Left", "up Left 100ms false", "down Right", "up Right 100ms false",
+	}
+	if got := describe(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("events =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestKeyProcessorTimeout(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := newKeyProcessor(KeyEventOptions{LongPress: 2 * time.Second})
+
+	events := p.command(command(Playback1, TV, opcodeVendorRemoteButtonDown, 0x91, 0x01), start)
+	down, ok := events[0].(KeyDownEvent)
+	if !ok || !down.Vendor || down.Key != KeyAnReturn || !reflect.DeepEqual(down.Operands, []byte{0x01}) {
+		t.Fatalf("unexpected events %+v", events)
+	}
+
+	next, ok := p.next()
+	if want := start.Add(DefaultKeyEventOptions.ReleaseTimeout); !ok || !next.Equal(want) {
+		t.Errorf("next() = %v, %t, want %v", next, ok, want)
+	}
+	if events := p.expire(start.Add(100 * time.Millisecond)); len(events) != 0 {
+		t.Errorf("expire before timeout = %v", describe(events))
+	}
+
+	events = p.expire(start.Add(time.Second))
+	want := []string{"up AnReturn 550ms true"}
+	if got := describe(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("expire after timeout = %q, want %q", got, want)
+	}
+	if _, ok := p.next(); ok {
+		t.Error("next() reports a deadline with no key held")
+	}
+}
+
+func TestKeyProcessorLongPressWithoutRepeat(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := newKeyProcessor(KeyEventOptions{LongPress: 300 * time.Millisecond, DoubleTap: -1})
+
+	p.command(command(TV, Recording1, opcodeUserControlPressed, byte(KeyPower)), start)
+	next, _ := p.next()
+	if want := start.Add(300 * time.Millisecond); !next.Equal(want) {
+		t.Errorf("next() = %v, want %v", next, want)
+	}
+
+	events := p.expire(next)
+	events = append(events, p.command(command(TV, Recording1, opcodeUserControlRelease), start.Add(400*time.Millisecond))...)
+	events = append(events, p.command(command(TV, Recording1, opcodeUserControlPressed, byte(KeyPower)), start.Add(500*time.Millisecond))...)
+
+	want := []string{"long Power", "up Power 400ms false", "down Power"}
+	if got := describe(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestKeyProcessorCallbackKeys(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	p := newKeyProcessor(KeyEventOptions{DoubleTap: -1})
+	var events []KeyEvent
+	run := func(ms int, evs []KeyEvent) {
+		// expire whatever became due before ms, as the KeyEvents loop does
+		for next, ok := p.next(); ok && !next.After(at(ms)); next, ok = p.next() {
+			events = append(events, p.expire(next)...)
+		}
+		events = append(events, evs...)
+	}
+	callback := func(ms int, key KeyCode, duration int) {
+		run(ms, p.keyPress(KeyPress{KeyCode: key, Duration: duration}, at(ms)))
+	}
+	message := func(ms int, opcode int, params ...byte) {
+		run(ms, p.command(command(TV, Recording1, opcode, params...), at(ms)))
+	}
+
+	// libcec reports the message as a key too, in either order
+	message(0, opcodeUserControlPressed, byte(KeySelect))
+	callback(5, KeySelect, 0)
+	message(200, opcodeUserControlRelease)
+	callback(205, KeySelect, 200)
+	callback(1000, KeyUp, 0)
+	message(1010, opcodeUserControlPressed, byte(KeyUp))
+	// a release from libcec releases the key held through messages
+	callback(1200, KeyUp, 190)
+	message(1210, opcodeUserControlRelease)
+	// vendor buttons are translated by libcec
+	message(2000, opcodeVendorRemoteButtonDown, 0x91)
+	callback(2010, KeyExit, 0)
+	message(2100, opcodeVendorRemoteButtonUp)
+	callback(2105, KeyExit, 95)
+	// keys only libcec knows about
+	callback(3000, KeyDown, 0)
+	callback(3300, KeyDown, 300)
+	callback(4000, KeyLeft, 150)
+	run(5000, nil)
+
+	want := []string{
+		"down Select", "up Select 200ms false",
+		"down Up", "up Up 190ms false",
+		"down AnReturn", "up AnReturn 100ms false",
+		"down Down", "up Down 300ms false",
+		"down Left", "up Left 150ms false",
+	}
+	if got := describe(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("events =\n%q\nwant\n%q", got, want)
+	}
+	for _, ev := range events[6:] {
+		if info, _ := ev.keyEvent(); info.Initiator != Unregistered {
+			t.Errorf("%T from %v, want Unregistered for callback keys", ev, info.Initiator)
+		}
+	}
+}
+
+func TestKeyProcessorCallbackRelease(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	p := newKeyProcessor(KeyEventOptions{DoubleTap: -1})
+	var events []KeyEvent
+	run := func(ms int, evs []KeyEvent) {
+		for next, ok := p.next(); ok && !next.After(at(ms)); next, ok = p.next() {
+			events = append(events, p.expire(next)...)
+		}
+		events = append(events, evs...)
+	}
+
+	// no release message, libcec releases the key after 500ms itself
+	run(0, p.command(command(TV, Recording1, opcodeUserControlPressed, byte(KeyUp)), at(0)))
+	run(1, p.keyPress(KeyPress{KeyCode: KeyUp}, at(1)))
+	run(500, p.keyPress(KeyPress{KeyCode: KeyUp, Duration: 500}, at(500)))
+	// a late release from libcec for a key released by a message
+	run(1000, p.command(command(TV, Recording1, opcodeUserControlPressed, byte(KeySelect)), at(1000)))
+	run(1100, p.command(command(TV, Recording1, opcodeUserControlRelease), at(1100)))
+	run(1400, p.keyPress(KeyPress{KeyCode: KeySelect, Duration: 400}, at(1400)))
+	run(3000, nil)
+
+	want := []string{"down Up", "up Up 500ms false", "down Select", "up Select 100ms false"}
+	if got := describe(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("events =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestKeyEventsFromCallback(t *testing.T) {
+	c := &Connection{done: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := c.KeyEvents(ctx, KeyEventOptions{DoubleTap: -1})
+
+	c.handleKeyPress(&KeyPress{KeyCode: KeyPlay, Duration: 0})
+	c.handleKeyPress(&KeyPress{KeyCode: KeyPlay, Duration: 120})
+
+	var got []KeyEvent
+	for len(got) < 2 {
+		select {
+		case ev := <-events:
+			got = append(got, ev)
+		case <-time.After(time.Second):
+			t.Fatalf("events = %q, want a press and release", describe(got))
+		}
+	}
+	if got := describe(got); got[0] != "down Play" || got[1] != "up Play 120ms false" {
+		t.Errorf("events = %q", got)
+	}
+}
@@ -0,0 +1,670 @@
+package cec
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyTrigger - the key event that triggers a binding
+type KeyTrigger int
+
+const (
+	// TriggerDown fires when the key is pressed
+	TriggerDown KeyTrigger = iota
+	// TriggerUp fires when the key is released
+	TriggerUp
+	// TriggerRepeat fires when the key is pressed and on every repeat
+	TriggerRepeat
+	// TriggerLongPress fires when the key is held for a long press
+	TriggerLongPress
+	// TriggerDoubleTap fires when the key is pressed twice
+	TriggerDoubleTap
+)
+
+var triggerNames = []string{"down", "up", "repeat", "long", "double"}
+
+func (t KeyTrigger) String() string {
+	if t >= 0 && int(t) < len(triggerNames) {
+		return triggerNames[t]
+	}
+	return fmt.Sprintf("KeyTrigger(%d)", int(t))
+}
+
+// KeyAction - RunAction, SendAction, EmitAction or ForwardAction taken
+// when a binding is triggered
+type KeyAction interface {
+	keyAction()
+}
+
+// RunAction - start a command, the key is passed in the environment as
+// CEC_KEY, CEC_INITIATOR and CEC_EVENT. The command keeps running when
+// RunKeymap stops
+type RunAction struct {
+	Command []string
+}
+
+// SendAction - transmit a CEC frame from our own address
+type SendAction struct {
+	Destination LogicalAddress
+	Opcode      int
+	Params      []byte
+}
+
+// EmitAction - pass a KeymapEvent with the given name on
+type EmitAction struct {
+	Name string
+}
+
+// ForwardAction - press and release the key on another device
+type ForwardAction struct {
+	Destination LogicalAddress
+}
+
+func (RunAction) keyAction()     {}
+func (SendAction) keyAction()    {}
+func (EmitAction) keyAction()    {}
+func (ForwardAction) keyAction() {}
+
+// KeyBinding - actions bound to a key or a gesture
+type KeyBinding struct {
+	// Key is the bound key, unless Gesture is set
+	Key KeyCode
+	// Gesture is the bound key sequence
+	Gesture []GestureStep
+	// Swallow drops the key events of the gesture
+	Swallow bool
+	// Initiators restricts the binding to remotes of these devices, empty
+	// matches all devices
+	Initiators []LogicalAddress
+	// On is the key event that triggers the binding, it is not used for
+	// gestures and forwarded keys
+	On      KeyTrigger
+	Actions []KeyAction
+}
+
+// KeymapEvent - sent by RunKeymap for an EmitAction, Event is the key
+// event that triggered it
+type KeymapEvent struct {
+	Name      string
+	Initiator LogicalAddress
+	Time      time.Time
+	Event     KeyEvent
+}
+
+func (ev KeymapEvent) keyEvent() (KeyInfo, bool) {
+	return KeyInfo{Initiator: ev.Initiator, Time: ev.Time}, false
+}
+
+// Keymap - key bindings loaded from a file in a small subset of TOML,
+// [[binding]] tables of single-line key = value pairs holding strings,
+// booleans or arrays of those:
+//
+//	[[binding]]
+//	key = "Red"              # key name or hex code
+//	initiator = "TV"         # optional, a name or a list of names
+//	on = "long"              # down (default), up, repeat, long or double
+//	run = ["systemctl", "suspend"]
+//
+//	[[binding]]
+//	gesture = "Stop:3s Power"
+//	swallow = true
+//	emit = "shutdown"
+//
+//	[[binding]]
+//	key = "VolumeUp"
+//	forward = "Audio"
+//
+//	[[binding]]
+//	key = "Blue"
+//	send = "TV:36"           # destination:opcode[:params] in hex
+//
+// A run command given as a string is run by sh -c
+type Keymap struct {
+	mu       sync.RWMutex
+	path     string
+	bindings []KeyBinding
+	gestures *GestureRecognizer
+	modTime  time.Time
+	size     int64
+}
+
+// LoadKeymap - load a keymap from a file
+func LoadKeymap(path string) (*Keymap, error) {
+	k := &Keymap{path: path}
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// ParseKeymap - parse a keymap, it cannot be reloaded
+func ParseKeymap(data string) (*Keymap, error) {
+	bindings, err := parseKeymap(data)
+	if err != nil {
+		return nil, err
+	}
+
+	k := new(Keymap)
+	if err := k.set(bindings); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload - read the keymap file again, the bindings are kept when it
+// cannot be loaded
+func (k *Keymap) Reload() error {
+	if k.path == "" {
+		return fmt.Errorf("Keymap was not loaded from a file")
+	}
+
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return err
+	}
+	bindings, err := parseKeymap(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", k.path, err)
+	}
+	if err := k.set(bindings); err != nil {
+		return fmt.Errorf("%s: %w", k.path, err)
+	}
+
+	k.mu.Lock()
+	k.modTime, k.size = info.ModTime(), info.Size()
+	k.mu.Unlock()
+	return nil
+}
+
+// WatchFile - reload the keymap whenever its file changes until ctx is
+// done, the file is checked every interval. Fails for keymaps that were
+// not loaded from a file
+func (k *Keymap) WatchFile(ctx context.Context, interval time.Duration) error {
+	if k.path == "" {
+		return fmt.Errorf("Keymap was not loaded from a file")
+	}
+	if interval <= 0 {
+		return fmt.Errorf("Invalid keymap watch interval %s", interval)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(k.path)
+			if err != nil {
+				slog.Error("Error checking keymap", "path", k.path, "error", err)
+				continue
+			}
+			k.mu.RLock()
+			changed := !info.ModTime().Equal(k.modTime) || info.Size() != k.size
+			k.mu.RUnlock()
+			if !changed {
+				continue
+			}
+
+			if err := k.Reload(); err != nil {
+				slog.Error("Error reloading keymap", "error", err)
+				// don't retry until the file changes again
+				k.mu.Lock()
+				k.modTime, k.size = info.ModTime(), info.Size()
+				k.mu.Unlock()
+				continue
+			}
+			slog.Info("Keymap reloaded", "path", k.path)
+		}
+	}()
+	return nil
+}
+
+// Bindings - the current bindings
+func (k *Keymap) Bindings() []KeyBinding {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return append([]KeyBinding(nil), k.bindings...)
+}
+
+func (k *Keymap) set(bindings []KeyBinding) error {
+	gestures, err := NewGestureRecognizer()
+	if err != nil {
+		return err
+	}
+	for i, b := range bindings {
+		if b.Gesture == nil {
+			continue
+		}
+		err := gestures.Add(Gesture{Name: gestureBindingName(i), Steps: b.Gesture, Swallow: b.Swallow})
+		if err != nil {
+			return err
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.bindings = bindings
+	k.gestures = gestures
+	return nil
+}
+
+func (k *Keymap) current() ([]KeyBinding, *GestureRecognizer) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.bindings, k.gestures
+}
+
+const gestureBindingPrefix = "keymap:"
+
+func gestureBindingName(i int) string {
+	return fmt.Sprintf("%s%d", gestureBindingPrefix, i)
+}
+
+// triggeredActions - the actions triggered by an event, forwarded keys
+// are triggered by every press, repeat and release
+func triggeredActions(bindings []KeyBinding, ev KeyEvent) []KeyAction {
+	var actions []KeyAction
+	for i, b := range bindings {
+		if gesture, ok := ev.(GestureEvent); ok {
+			if b.Gesture != nil && gesture.Name == gestureBindingName(i) && matchesInitiator(b, gesture.Initiator) {
+				actions = append(actions, b.Actions...)
+			}
+			continue
+		}
+
+		info, isKey := ev.keyEvent()
+		if !isKey || b.Gesture != nil || info.Vendor || info.Key != b.Key || !matchesInitiator(b, info.Initiator) {
+			continue
+		}
+		for _, a := range b.Actions {
+			if _, ok := a.(ForwardAction); ok {
+				switch ev.(type) {
+				case KeyDownEvent, KeyRepeatEvent, KeyUpEvent:
+					actions = append(actions, a)
+				}
+			} else if triggers(b.On, ev) {
+				actions = append(actions, a)
+			}
+		}
+	}
+	return actions
+}
+
+// triggers - whether a key event fires a trigger
+func triggers(on KeyTrigger, ev KeyEvent) bool {
+	switch ev.(type) {
+	case KeyDownEvent:
+		return on == TriggerDown || on == TriggerRepeat
+	case KeyRepeatEvent:
+		return on == TriggerRepeat
+	case KeyUpEvent:
+		return on == TriggerUp
+	case LongPressEvent:
+		return on == TriggerLongPress
+	case DoubleTapEvent:
+		return on == TriggerDoubleTap
+	}
+	return false
+}
+
+func matchesInitiator(b KeyBinding, initiator LogicalAddress) bool {
+	if len(b.Initiators) == 0 {
+		return true
+	}
+	for _, address := range b.Initiators {
+		if address == initiator {
+			return true
+		}
+	}
+	return false
+}
+
+// RunKeymap - pass the events from in on and take the actions of the
+// bindings they trigger, adding a KeymapEvent for every EmitAction. The
+// returned channel is closed when in is closed or ctx is done
+func (c *Connection) RunKeymap(ctx context.Context, k *Keymap, in <-chan KeyEvent) <-chan KeyEvent {
+	out := make(chan KeyEvent, 16)
+
+	go func() {
+		defer close(out)
+
+		emit := func(ev KeyEvent) bool {
+			select {
+			case out <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		handle := func(bindings []KeyBinding, events []KeyEvent) bool {
+			for _, ev := range events {
+				gesture, ok := ev.(GestureEvent)
+				// the gestures of the keymap are only passed on by EmitAction
+				if (!ok || !strings.HasPrefix(gesture.Name, gestureBindingPrefix)) && !emit(ev) {
+					return false
+				}
+				for _, a := range triggeredActions(bindings, ev) {
+					if named, ok := c.runAction(ctx, a, ev); ok && !emit(named) {
+						return false
+					}
+				}
+			}
+			return true
+		}
+
+		bindings, gestures := k.current()
+		for {
+			var wake <-chan time.Time
+			if next, ok := gestures.next(); ok {
+				wake = time.After(time.Until(next))
+			}
+
+			var events []KeyEvent
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-in:
+				if !ok {
+					handle(bindings, gestures.flush())
+					return
+				}
+				if newBindings, newGestures := k.current(); newGestures != gestures {
+					// the keymap was reloaded
+					if !handle(bindings, gestures.flush()) {
+						return
+					}
+					bindings, gestures = newBindings, newGestures
+				}
+				events = gestures.feed(ev)
+			case now := <-wake:
+				events = gestures.expire(now)
+			}
+			if !handle(bindings, events) {
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// runAction - take an action for an event, returns the KeymapEvent of an
+// EmitAction
+func (c *Connection) runAction(ctx context.Context, action KeyAction, ev KeyEvent) (KeyEvent, bool) {
+	info, isKey := ev.keyEvent()
+
+	switch a := action.(type) {
+	case RunAction:
+		key := ""
+		if isKey {
+			key = info.Key.String()
+		}
+		cmd := exec.Command(a.Command[0], a.Command[1:]...)
+		cmd.Env = append(os.Environ(),
+			"CEC_KEY="+key,
+			"CEC_INITIATOR="+info.Initiator.String(),
+			"CEC_EVENT="+keyEventName(ev))
+		if err := cmd.Start(); err != nil {
+			slog.Error("Error running keymap command", "command", a.Command, "error", err)
+			return nil, false
+		}
+		go func() {
+			if err := cmd.Wait(); err != nil {
+				slog.Error("Keymap command failed", "command", a.Command, "error", err)
+			}
+		}()
+	case SendAction:
+		if err := c.transmit(ctx, a.Destination, a.Opcode, a.Params...); err != nil {
+			slog.Error("Error sending keymap frame", "destination", a.Destination, "opcode", a.Opcode, "error", err)
+		}
+	case EmitAction:
+		return KeymapEvent{Name: a.Name, Initiator: info.Initiator, Time: info.Time, Event: ev}, true
+	case ForwardAction:
+		var err error
+		switch ev.(type) {
+		case KeyDownEvent, KeyRepeatEvent:
+			err = c.KeyPressContext(ctx, a.Destination, info.Key, info.Operands...)
+		case KeyUpEvent:
+			err = c.KeyReleaseContext(ctx, a.Destination)
+		}
+		if err != nil {
+			slog.Error("Error forwarding key", "key", info.Key, "destination", a.Destination, "error", err)
+		}
+	}
+	return nil, false
+}
+
+// keyEventName - the trigger name of an event, or the gesture name
+func keyEventName(ev KeyEvent) string {
+	switch ev := ev.(type) {
+	case KeyDownEvent:
+		return TriggerDown.String()
+	case KeyRepeatEvent:
+		return TriggerRepeat.String()
+	case KeyUpEvent:
+		return TriggerUp.String()
+	case LongPressEvent:
+		return TriggerLongPress.String()
+	case DoubleTapEvent:
+		return TriggerDoubleTap.String()
+	case GestureEvent:
+		return ev.Name
+	}
+	return ""
+}
+
+// parseKeymap - convert a keymap file into bindings
+func parseKeymap(data string) ([]KeyBinding, error) {
+	tables, err := parseTOML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var bindings []KeyBinding
+	for i, table := range tables {
+		b, err := parseBinding(table)
+		if err != nil {
+			return nil, fmt.Errorf("Binding %d: %w", i+1, err)
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings, nil
+}
+
+func parseBinding(table tomlTable) (KeyBinding, error) {
+	var b KeyBinding
+
+	key, hasKey := table["key"]
+	gesture, hasGesture := table["gesture"]
+	switch {
+	case hasKey && hasGesture:
+		return b, fmt.Errorf("Both key and gesture set")
+	case hasKey:
+		name, ok := key.(string)
+		if !ok {
+			return b, fmt.Errorf("key must be a string")
+		}
+		code := GetKeyCodeByName(name)
+		if code < 0 {
+			return b, fmt.Errorf("Unknown key %q", name)
+		}
+		b.Key = KeyCode(code)
+	case hasGesture:
+		text, ok := gesture.(string)
+		if !ok {
+			return b, fmt.Errorf("gesture must be a string")
+		}
+		steps, err := ParseGestureSteps(text)
+		if err != nil {
+			return b, err
+		}
+		b.Gesture = steps
+	default:
+		return b, fmt.Errorf("Neither key nor gesture set")
+	}
+
+	for name := range table {
+		switch name {
+		case "key", "gesture", "swallow", "initiator", "on", "run", "send", "forward", "emit":
+		default:
+			return b, fmt.Errorf("Unknown setting %s", name)
+		}
+	}
+
+	// actions are taken in this order
+	for _, name := range []string{"swallow", "initiator", "on", "run", "send", "forward", "emit"} {
+		value, ok := table[name]
+		if !ok {
+			continue
+		}
+
+		var err error
+		switch name {
+		case "swallow":
+			swallow, ok := value.(bool)
+			if !ok {
+				return b, fmt.Errorf("swallow must be true or false")
+			}
+			b.Swallow = swallow
+		case "initiator":
+			b.Initiators, err = parseInitiators(value)
+		case "on":
+			b.On, err = parseTrigger(value)
+		case "run":
+			var action RunAction
+			action, err = parseRun(value)
+			b.Actions = append(b.Actions, action)
+		case "send":
+			var action SendAction
+			action, err = parseSend(value)
+			b.Actions = append(b.Actions, action)
+		case "emit":
+			name, ok := value.(string)
+			if !ok || name == "" {
+				return b, fmt.Errorf("emit must be a name")
+			}
+			b.Actions = append(b.Actions, EmitAction{Name: name})
+		case "forward":
+			text, ok := value.(string)
+			if !ok {
+				return b, fmt.Errorf("forward must be a device")
+			}
+			var address LogicalAddress
+			address, err = ParseLogicalAddress(text)
+			b.Actions = append(b.Actions, ForwardAction{Destination: address})
+		}
+		if err != nil {
+			return b, err
+		}
+	}
+
+	if len(b.Actions) == 0 {
+		return b, fmt.Errorf("No action set")
+	}
+	if b.Swallow && b.Gesture == nil {
+		return b, fmt.Errorf("swallow is only supported for gestures")
+	}
+	return b, nil
+}
+
+func parseInitiators(value interface{}) ([]LogicalAddress, error) {
+	var names []interface{}
+	switch value := value.(type) {
+	case string:
+		names = []interface{}{value}
+	case []interface{}:
+		names = value
+	default:
+		return nil, fmt.Errorf("initiator must be a device or a list of devices")
+	}
+
+	var addresses []LogicalAddress
+	for _, name := range names {
+		text, ok := name.(string)
+		if !ok {
+			return nil, fmt.Errorf("initiator must be a device or a list of devices")
+		}
+		address, err := ParseLogicalAddress(text)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+func parseTrigger(value interface{}) (KeyTrigger, error) {
+	text, _ := value.(string)
+	for i, name := range triggerNames {
+		if strings.EqualFold(text, name) {
+			return KeyTrigger(i), nil
+		}
+	}
+	return 0, fmt.Errorf("on must be one of %s", strings.Join(triggerNames, ", "))
+}
+
+func parseRun(value interface{}) (RunAction, error) {
+	switch value := value.(type) {
+	case string:
+		if strings.TrimSpace(value) == "" {
+			break
+		}
+		return RunAction{Command: []string{"sh", "-c", value}}, nil
+	case []interface{}:
+		var command []string
+		for _, arg := range value {
+			text, ok := arg.(string)
+			if !ok {
+				return RunAction{}, fmt.Errorf("run must be a string or a list of strings")
+			}
+			command = append(command, text)
+		}
+		if len(command) > 0 {
+			return RunAction{Command: command}, nil
+		}
+	}
+	return RunAction{}, fmt.Errorf("run must be a string or a list of strings")
+}
+
+// parseSend - parse a frame given as destination:opcode[:params], e.g.
+// "TV:36" or "5:44:41"
+func parseSend(value interface{}) (SendAction, error) {
+	text, ok := value.(string)
+	if !ok {
+		return SendAction{}, fmt.Errorf("send must be a frame")
+	}
+
+	parts := strings.Split(text, ":")
+	if len(parts) < 2 {
+		return SendAction{}, fmt.Errorf("Invalid frame %q", text)
+	}
+	destination, err := ParseLogicalAddress(parts[0])
+	if err != nil {
+		return SendAction{}, err
+	}
+	data, err := hex.DecodeString(strings.Join(parts[1:], ""))
+	if err != nil || len(data) == 0 || len(data) > 15 {
+		return SendAction{}, fmt.Errorf("Invalid frame %q", text)
+	}
+
+	return SendAction{Destination: destination, Opcode: int(data[0]), Params: data[1:]}, nil
+}
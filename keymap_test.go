@@ -0,0 +1,263 @@
+package cec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testKeymap = `
+# media PC
+[[binding]]
+key = "Red"
+initiator = ["TV", "Playback 1"]
+on = "long"
+run = ["systemctl", "suspend"]
+
+[[binding]]
+gesture = "Stop:3s Power"
+swallow = true
+emit = "shutdown"
+
+[[binding]]
+key = "0x41"   # volume up
+forward = "Audio"
+
+[[binding]]
+key = 'blue'
+send = "TV:36"
+emit = 'standby'
+`
+
+func TestParseKeymap(t *testing.T) {
+	k, err := ParseKeymap(testKeymap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []KeyBinding{
+		{Key: KeyRed, Initiators: []LogicalAddress{TV, Playback1}, On: TriggerLongPress,
+			Actions: []KeyAction{RunAction{Command: []string{"systemctl", "suspend"}}}},
+		{Gesture: []GestureStep{{Key: KeyStop, Hold: 3 * time.Second}, {Key: KeyPower}}, Swallow: true,
+			Actions: []KeyAction{EmitAction{Name: "shutdown"}}},
+		{Key: KeyVolumeUp, Actions: []KeyAction{ForwardAction{Destination: AudioSystem}}},
+		{Key: KeyBlue, Actions: []KeyAction{SendAction{Destination: TV, Opcode: opcodeStandby, Params: []byte{}},
+			EmitAction{Name: "standby"}}},
+	}
+	if got := k.Bindings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Bindings() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseKeymapErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"[[binding]]\nkey = \"Nope\"\nemit = \"x\"", "Unknown key"},
+		{"[[binding]]\nkey = \"Red\"", "No action"},
+		{"[[binding]]\nkey = \"Red\"\ngesture = \"Red\"\nemit = \"x\"", "Both key and gesture"},
+		{"[[binding]]\nkey = \"Red\"\nemit = \"x\"\ncolour = \"blue\"", "Unknown setting colour"},
+		{"[[binding]]\nkey = \"Red\"\non = \"sideways\"\nemit = \"x\"", "on must be one of"},
+		{"[[binding]]\nkey = \"Red\"\nsend = \"TV:zz\"", "Invalid frame"},
+		{"[[binding]]\nkey = \"Red\"\nswallow = true\nemit = \"x\"", "swallow is only supported"},
+		{"[[binding]]\nkey = \"Red\"\nemit = \"x\"\nemit = \"y\"", "Line 4: Duplicate key emit"},
+		{"[[keys]]", "Unknown table [[keys]]"},
+		{"key = \"Red", "Line 1: Unterminated string"},
+		{"[[binding]]\nkey = \"Red\" emit = \"x\"", "Line 2: Unexpected"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseKeymap(tt.in)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseKeymap(%q) = %v, want error containing %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	tables, err := parseTOML(`# keymap
+[[binding]]
+quoted = "a\"b\\c\n" # comment
+literal = 'C:\path'
+list = ["x", 'y', true, ]
+[[ binding ]]
+
+empty = []
+flag = false
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []tomlTable{
+		{
+			"quoted":  "a\"b\\c\n",
+			"literal": `C:\path`,
+			"list":    []interface{}{"x", "y", true},
+		},
+		{"empty": []interface{}{}, "flag": false},
+	}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("parseTOML() = %v, want %v", tables, want)
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"top = \"x\"", "Line 1: top set outside a [[binding]]"},
+		{"[table]", "Unknown table [table]"},
+		{"[[binding]]\n[[other]]", "Line 2: Unknown table [[other]]"},
+		{"[[binding]] x", "Unexpected"},
+		{"[[binding]]\nn = 1", "Unsupported value 1"},
+		{"[[binding]]\nlist = [\n\"x\"]", "Unterminated array"},
+		{"[[binding]]\nlist = [[\"x\"]]", "Unsupported value"},
+		{"[[binding]]\nlist = [\"x\" \"y\"]", "Expected , or ]"},
+		{"[[binding]]\n\"quoted\" = \"x\"", "Expected key"},
+		{"[[binding]]\nkey \"x\"", "Expected = after key"},
+		{"[[binding]]\nkey = \"\\u0041\"", "Unsupported escape"},
+		{"[[binding]]\nkey = ", "Expected value"},
+	}
+
+	for _, tt := range tests {
+		_, err := parseTOML(tt.in)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseTOML(%q) = %v, want error containing %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestWatchFileWithoutPath(t *testing.T) {
+	k, err := ParseKeymap("[[binding]]\nkey = \"Red\"\nemit = \"red\"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.WatchFile(context.Background(), time.Second); err == nil {
+		t.Error("WatchFile() of a parsed keymap succeeded")
+	}
+}
+
+func TestTriggeredActions(t *testing.T) {
+	k, err := ParseKeymap(testKeymap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bindings, _ := k.current()
+
+	red := KeyInfo{Initiator: TV, Key: KeyRed}
+	volume := KeyInfo{Initiator: TV, Key: KeyVolumeUp}
+	tests := []struct {
+		name string
+		ev   KeyEvent
+		want int
+	}{
+		{"red down", KeyDownEvent{KeyInfo: red}, 0},
+		{"red long", LongPressEvent{KeyInfo: red}, 1},
+		{"red long other remote", LongPressEvent{KeyInfo: KeyInfo{Initiator: Playback2, Key: KeyRed}}, 0},
+		{"forward down", KeyDownEvent{KeyInfo: volume}, 1},
+		{"forward repeat", KeyRepeatEvent{KeyInfo: volume}, 1},
+		{"forward up", KeyUpEvent{KeyInfo: volume}, 1},
+		{"forward long", LongPressEvent{KeyInfo: volume}, 0},
+		{"blue down", KeyDownEvent{KeyInfo: KeyInfo{Key: KeyBlue}}, 2},
+		{"gesture", GestureEvent{Name: gestureBindingName(1)}, 1},
+		{"other gesture", GestureEvent{Name: "other"}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := triggeredActions(bindings, tt.ev); len(got) != tt.want {
+			t.Errorf("%s: %d actions %v, want %d", tt.name, len(got), got, tt.want)
+		}
+	}
+}
+
+func TestKeymapReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keymap.toml")
+	if err := os.WriteFile(path, []byte("[[binding]]\nkey = \"Red\"\nemit = \"red\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := LoadKeymap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("[[binding]]\nkey = \"Nope\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Reload(); err == nil {
+		t.Error("Reload() of a broken keymap succeeded")
+	}
+	if got := k.Bindings(); len(got) != 1 || got[0].Key != KeyRed {
+		t.Errorf("bindings after failed reload = %+v", got)
+	}
+
+	if err := os.WriteFile(path, []byte("[[binding]]\nkey = \"Green\"\nemit = \"green\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := k.Bindings(); len(got) != 1 || got[0].Key != KeyGreen {
+		t.Errorf("bindings after reload = %+v", got)
+	}
+}
+
+func TestRunKeymapEmit(t *testing.T) {
+	k, err := ParseKeymap("[[binding]]\nkey = \"Red\"\nemit = \"red\"\n\n[[binding]]\ngesture = \"Green Green\"\nswallow = true\nemit = \"greens\"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := make(chan KeyEvent, 8)
+	start := time.Now()
+	for i, key := range []KeyCode{KeyRed, KeyGreen, KeyGreen} {
+		info := KeyInfo{Initiator: TV, Key: key, Time: start.Add(time.Duration(i) * 100 * time.Millisecond)}
+		in <- KeyDownEvent{KeyInfo: info}
+		info.Time = info.Time.Add(50 * time.Millisecond)
+		in <- KeyUpEvent{KeyInfo: info, Held: 50 * time.Millisecond}
+	}
+	close(in)
+
+	var got []string
+	for ev := range new(Connection).RunKeymap(context.Background(), k, in) {
+		if ev, ok := ev.(KeymapEvent); ok {
+			got = append(got, "emit "+ev.Name)
+			continue
+		}
+		got = append(got, describe([]KeyEvent{ev})...)
+	}
+
+	want := []string{"down Red", "emit red", "up Red 50ms false", "emit greens"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestRunActionOutlivesKeymap(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "key")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// the command finishes after RunKeymap's ctx is done
+	action := RunAction{Command: []string{"sh", "-c", `sleep 0.1; echo "$CEC_KEY" > "$0"`, out}}
+	new(Connection).runAction(ctx, action, KeyDownEvent{KeyInfo{Initiator: TV, Key: KeySelect}})
+	cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		data, err := os.ReadFile(out)
+		if err == nil && strings.TrimSpace(string(data)) == "Select" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("command output %q, %v, want the key written", data, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
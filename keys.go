@@ -0,0 +1,192 @@
+package cec
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyTiming - how keys are sent to a device. Press is how long a single
+// key is held before it is released, Release is the minimum pause after a
+// release before the next key and Repeat is the interval at which
+// USER_CONTROL_PRESSED is repeated while a key is held
+type KeyTiming struct {
+	Press   time.Duration
+	Release time.Duration
+	Repeat  time.Duration
+}
+
+// DefaultKeyTiming - timing used for devices without their own, the
+// repeat interval is within the 200-450ms required by the CEC spec
+var DefaultKeyTiming = KeyTiming{
+	Press:   10 * time.Millisecond,
+	Release: 50 * time.Millisecond,
+	Repeat:  300 * time.Millisecond,
+}
+
+type keyTimings struct {
+	mu      sync.Mutex
+	devices map[LogicalAddress]KeyTiming
+}
+
+// SetKeyTiming - set the key timing for the device at the given address,
+// zero fields fall back to DefaultKeyTiming
+func (c *Connection) SetKeyTiming(address LogicalAddress, timing KeyTiming) {
+	c.keyTimings.mu.Lock()
+	defer c.keyTimings.mu.Unlock()
+
+	if c.keyTimings.devices == nil {
+		c.keyTimings.devices = make(map[LogicalAddress]KeyTiming)
+	}
+	c.keyTimings.devices[address] = timing
+}
+
+// KeyTiming - the key timing used for the device at the given address
+func (c *Connection) KeyTiming(address LogicalAddress) KeyTiming {
+	c.keyTimings.mu.Lock()
+	timing := c.keyTimings.devices[address]
+	c.keyTimings.mu.Unlock()
+
+	if timing.Press <= 0 {
+		timing.Press = DefaultKeyTiming.Press
+	}
+	if timing.Release <= 0 {
+		timing.Release = DefaultKeyTiming.Release
+	}
+	if timing.Repeat <= 0 {
+		timing.Repeat = DefaultKeyTiming.Repeat
+	}
+	return timing
+}
+
+// HoldKey - press the key and hold it for duration, repeating the press at
+// the device's repeat interval, the key is always released even when ctx
+// is done
+func (c *Connection) HoldKey(ctx context.Context, address LogicalAddress, key interface{}, duration time.Duration) error {
+	command, err := keyCommand(key)
+	if err != nil {
+		return err
+	}
+	timing := c.KeyTiming(address)
+
+	err = c.KeyPressContext(ctx, address, command.Key, command.Operands...)
+	if err != nil {
+		return fmt.Errorf("Error handling key press: %w", err)
+	}
+
+	release := time.NewTimer(duration)
+	defer release.Stop()
+	repeat := time.NewTicker(timing.Repeat)
+	defer repeat.Stop()
+
+hold:
+	for {
+		select {
+		case <-release.C:
+			break hold
+		case <-ctx.Done():
+			break hold
+		case <-repeat.C:
+			err = c.KeyPressContext(ctx, address, command.Key, command.Operands...)
+			if err != nil && ctx.Err() == nil {
+				err = fmt.Errorf("Error repeating key press: %w", err)
+				break hold
+			}
+			err = nil
+		}
+	}
+
+	if er := c.KeyReleaseContext(context.WithoutCancel(ctx), address); er != nil && err == nil {
+		err = fmt.Errorf("Error handling key release: %w", er)
+	}
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// KeySequence - send the keys one after another, pausing gap between them
+// but at least the device's release time
+func (c *Connection) KeySequence(address LogicalAddress, keys []KeyCode, gap time.Duration) error {
+	return c.KeySequenceContext(context.Background(), address, keys, gap)
+}
+
+// KeySequenceContext - like KeySequence, but stops when ctx is done
+func (c *Connection) KeySequenceContext(ctx context.Context, address LogicalAddress, keys []KeyCode, gap time.Duration) error {
+	if release := c.KeyTiming(address).Release; gap < release {
+		gap = release
+	}
+
+	for i, key := range keys {
+		if i > 0 {
+			if err := sleep(ctx, gap); err != nil {
+				return err
+			}
+		}
+		if err := c.KeyContext(ctx, address, key); err != nil {
+			return fmt.Errorf("Error sending key %d (%s): %w", i+1, key, err)
+		}
+	}
+	return nil
+}
+
+// ParseKeySequence - parse keys separated by spaces or commas, such as
+// "1 2 3 Enter", names containing spaces must be written without them
+func ParseKeySequence(s string) ([]KeyCode, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("Empty key sequence")
+	}
+
+	keys := make([]KeyCode, 0, len(fields))
+	for _, field := range fields {
+		key, err := ParseKeyCode(field)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// keyCommand - the UI command for a key given as a name or hex-code
+// string, a KeyCode, an int or a UICommand
+func keyCommand(key interface{}) (UICommand, error) {
+	switch key := key.(type) {
+	case string:
+		code, err := ParseKeyCode(key)
+		if err != nil {
+			return UICommand{}, err
+		}
+		return UICommand{Key: code}, nil
+	case KeyCode:
+		return UICommand{Key: key}, nil
+	case int:
+		return UICommand{Key: KeyCode(key)}, nil
+	case UICommand:
+		return key, nil
+	default:
+		return UICommand{}, fmt.Errorf("Invalid key type %T", key)
+	}
+}
+
+// sleep - wait for d, returns early with the error when ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
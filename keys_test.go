@@ -0,0 +1,169 @@
+package cec
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseKeySequence(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []KeyCode
+		ok   bool
+	}{
+		{"1 2 3 Enter", []KeyCode{Key1, Key2, Key3, KeyEnter}, true},
+		{"Red,Red, Green", []KeyCode{KeyRed, KeyRed, KeyGreen}, true},
+		{"channel_up 0x44", []KeyCode{KeyChannelUp, KeyPlay}, true},
+		{"", nil, false},
+		{"1 nope", nil, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseKeySequence(tt.in)
+		if (err == nil) != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseKeySequence(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestKeyTiming(t *testing.T) {
+	c := new(Connection)
+
+	if got := c.KeyTiming(TV); got != DefaultKeyTiming {
+		t.Errorf("default timing = %+v, want %+v", got, DefaultKeyTiming)
+	}
+
+	c.SetKeyTiming(Playback1, KeyTiming{Press: 100 * time.Millisecond})
+	want := DefaultKeyTiming
+	want.Press = 100 * time.Millisecond
+	if got := c.KeyTiming(Playback1); got != want {
+		t.Errorf("Playback1 timing = %+v, want %+v", got, want)
+	}
+	if got := c.KeyTiming(TV); got != DefaultKeyTiming {
+		t.Errorf("TV timing = %+v, want %+v", got, DefaultKeyTiming)
+	}
+}
+
+func TestKeyCommand(t *testing.T) {
+	play := PlayFunctionCommand(PlayForward)
+	tests := []struct {
+		in   interface{}
+		want UICommand
+		ok   bool
+	}{
+		{"Select", UICommand{Key: KeySelect}, true},
+		{"0x2B", UICommand{Key: KeyEnter}, true},
+		{"x", UICommand{}, false},
+		{KeyMute, UICommand{Key: KeyMute}, true},
+		{0x41, UICommand{Key: KeyVolumeUp}, true},
+		{play, play, true},
+		{1.5, UICommand{}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := keyCommand(tt.in)
+		if (err == nil) != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("keyCommand(%v) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+// keyTimes - the times keys were pressed and released on the bus
+func (b *fakeBus) keyTimes() (presses []time.Time, releases []time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, msg := range b.sent {
+		switch msg.Opcode {
+		case opcodeUserControlPressed:
+			presses = append(presses, b.sentAt[i])
+		case opcodeUserControlRelease:
+			releases = append(releases, b.sentAt[i])
+		}
+	}
+	return presses, releases
+}
+
+func TestHoldKey(t *testing.T) {
+	bus := newFakeBus(Recording1)
+	repeat := 20 * time.Millisecond
+	bus.c.SetKeyTiming(Playback1, KeyTiming{Repeat: repeat})
+
+	if err := bus.c.HoldKey(context.Background(), Playback1, KeyUp, 110*time.Millisecond); err != nil {
+		t.Fatalf("HoldKey() = %v", err)
+	}
+
+	presses, releases := bus.keyTimes()
+	if len(presses) < 3 || len(releases) != 1 {
+		t.Fatalf("sent %v, want the key repeated and released once", bus.opcodes())
+	}
+	for i := 1; i < len(presses); i++ {
+		if gap := presses[i].Sub(presses[i-1]); gap < repeat/2 {
+			t.Errorf("repeat %d after %v, want every %v", i, gap, repeat)
+		}
+	}
+	if held := releases[0].Sub(presses[0]); held < 110*time.Millisecond {
+		t.Errorf("released after %v", held)
+	}
+	if last := bus.opcodes()[len(bus.opcodes())-1]; last != "USER_CONTROL_RELEASE" {
+		t.Errorf("last message %s, want the release", last)
+	}
+}
+
+func TestHoldKeyCancel(t *testing.T) {
+	bus := newFakeBus(Recording1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := bus.c.HoldKey(ctx, Playback1, KeyUp, time.Hour); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("HoldKey() = %v, want the deadline", err)
+	}
+	sent := bus.opcodes()
+	if len(sent) == 0 || sent[len(sent)-1] != "USER_CONTROL_RELEASE" {
+		t.Errorf("sent %v, want the key released", sent)
+	}
+}
+
+func TestKeySequence(t *testing.T) {
+	tests := []struct {
+		gap  time.Duration
+		want time.Duration
+	}{
+		// the device's release time is the minimum gap
+		{10 * time.Millisecond, 30 * time.Millisecond},
+		{50 * time.Millisecond, 50 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		bus := newFakeBus(Recording1)
+		bus.c.SetKeyTiming(TV, KeyTiming{Press: 5 * time.Millisecond, Release: 30 * time.Millisecond})
+
+		err := bus.c.KeySequenceContext(context.Background(), TV, []KeyCode{Key1, Key2, Key3}, tt.gap)
+		if err != nil {
+			t.Fatalf("KeySequenceContext() = %v", err)
+		}
+
+		presses, releases := bus.keyTimes()
+		if len(presses) != 3 || len(releases) != 3 {
+			t.Fatalf("sent %v, want three keys", bus.opcodes())
+		}
+		for i := 1; i < 3; i++ {
+			if gap := presses[i].Sub(releases[i-1]); gap < tt.want {
+				t.Errorf("gap %v: key %d pressed %v after the release, want %v", tt.gap, i+1, gap, tt.want)
+			}
+		}
+	}
+
+	bus := newFakeBus(Recording1)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := bus.c.KeySequenceContext(ctx, TV, []KeyCode{Key1, Key2, Key3}, time.Hour)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("KeySequenceContext() = %v, want the deadline", err)
+	}
+	if presses, _ := bus.keyTimes(); len(presses) != 1 {
+		t.Errorf("sent %v after the deadline, want only the first key", bus.opcodes())
+	}
+}
@@ -17,6 +17,7 @@ void keyPressed(void *, const cec_keypress *);
 void alertReceived(void *, const libcec_alert, const libcec_parameter);
 void sourceActivated(void *, const cec_logical_address, uint8_t activated);
 int menuStateChanged(void *, const cec_menu_state);
+int commandHandler(void *, const cec_command *);
 
 libcec_configuration * allocConfiguration()  {
 	libcec_configuration * ret = (libcec_configuration*)malloc(sizeof(libcec_configuration));
@@ -37,6 +38,7 @@ void setupCallbacks(libcec_configuration *conf)
 	g_callbacks.alert = &alertReceived;
 	g_callbacks.menuStateChanged = &menuStateChanged;
 	g_callbacks.sourceActivated = &sourceActivated;
+	g_callbacks.commandHandler = &commandHandler;
 	(*conf).callbacks = &g_callbacks;
 }
 
@@ -49,15 +51,35 @@ void setName(libcec_configuration *conf, char *name)
 import "C"
 
 import (
+	"bytes"
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
 	"log/slog"
 	"strings"
+	"sync"
+	"time"
 	"unsafe"
 )
 
+// libcecHooks - stand-ins for the libcec calls sending and answering
+// messages, lets tests drive a Connection without an adapter
+type libcecHooks struct {
+	ownAddress    LogicalAddress
+	transmit      func(msg *Command) bool
+	powerStatus   func(address LogicalAddress) PowerStatus
+	activeDevices func() []LogicalAddress
+	poll          func(address LogicalAddress) bool
+	activeSource  func(address LogicalAddress) bool
+	osdName       func(address LogicalAddress) string
+	powerOn       func(address LogicalAddress) bool
+	standby       func(address LogicalAddress) bool
+	keyPress      func(address LogicalAddress, key KeyCode) bool
+	keyRelease    func(address LogicalAddress) bool
+}
+
 // Connection class
 type Connection struct {
 	connection        C.libcec_connection_t
@@ -66,6 +88,46 @@ type Connection struct {
 	Messages          chan string
 	SourceActivations chan *SourceActivation
 	MenuActivations   chan bool
+	// ActiveSourceChanges receives the new active source whenever it
+	// changes
+	ActiveSourceChanges chan *ActiveSourceInfo
+
+	// OnCommand is called for every received command before libcec
+	// handles it, returning true tells libcec not to take any action
+	OnCommand func(Command) bool
+	// OnKeyPress is called for every key press, returning true keeps the
+	// key out of KeyPresses. libcec's key press callback returns nothing,
+	// so the result has no effect on libcec itself
+	OnKeyPress func(KeyPress) bool
+	// OnMenuStateChanged is called when the menu state is about to change,
+	// returning false refuses the change
+	OnMenuStateChanged func(MenuState) bool
+	// OnPanic is called when a panic is recovered in a callback
+	OnPanic func(*CallbackPanic)
+
+	mu        sync.Mutex
+	closed    bool
+	done      chan struct{}
+	dispatch  sync.WaitGroup
+	calls     sync.WaitGroup
+	closeOnce sync.Once
+	closeDone chan struct{}
+
+	// hooks replaces libcec calls in tests
+	hooks *libcecHooks
+
+	abortMu sync.Mutex
+	aborts  map[featureAbortKey]time.Time
+
+	listenMu     sync.Mutex
+	listeners    map[int]func(*Command)
+	keyListeners map[int]func(*KeyPress)
+	nextListener int
+
+	resolver     resolver
+	state        stateCache
+	activeSource activeSourceTracker
+	keyTimings   keyTimings
 }
 
 type cecAdapter struct {
@@ -85,7 +147,10 @@ func cecInit(c *Connection, deviceName string) (C.libcec_connection_t, error) {
 	conf.callbackParam = unsafe.Pointer(c)
 	conf.bActivateSource = 0
 
-	C.setName(conf, C.CString(deviceName))
+	name := C.CString(deviceName)
+	defer C.free(unsafe.Pointer(name))
+
+	C.setName(conf, name)
 	C.setupCallbacks(conf)
 
 	connection = C.libcec_initialise(conf)
@@ -111,7 +176,7 @@ func getAdapter(connection C.libcec_connection_t, name string) (cecAdapter, erro
 		}
 	}
 
-	return adapter, errors.New("No Device Found")
+	return adapter, ErrNoAdapter
 }
 
 func openAdapter(connection C.libcec_connection_t, adapter cecAdapter) error {
@@ -119,7 +184,10 @@ func openAdapter(connection C.libcec_connection_t, adapter cecAdapter) error {
 	C.libcec_init_video_standalone(connection)
 
 	slog.Debug("libcec_open")
-	result := C.libcec_open(connection, C.CString(adapter.Comm), C.CEC_DEFAULT_CONNECT_TIMEOUT)
+	comm := C.CString(adapter.Comm)
+	defer C.free(unsafe.Pointer(comm))
+
+	result := C.libcec_open(connection, comm, C.CEC_DEFAULT_CONNECT_TIMEOUT)
 	if result < 1 {
 		return errors.New("Failed to open adapter")
 	}
@@ -186,35 +254,161 @@ func CreateCommand(command string) C.cec_command {
 
 // Transmit CEC command - command is encoded as a hex string with
 // colons (e.g. "40:04")
-func (c *Connection) Transmit(command string) {
+func (c *Connection) Transmit(command string) error {
+	return c.TransmitContext(context.Background(), command)
+}
+
+// TransmitContext - like Transmit, but gives up when ctx is done
+func (c *Connection) TransmitContext(ctx context.Context, command string) error {
 	cecCommand := CreateCommand(command)
-	C.libcec_transmit(c.connection, (*C.cec_command)(&cecCommand))
+
+	var result int
+	err := c.call(ctx, func() {
+		result = int(C.libcec_transmit(c.connection, (*C.cec_command)(&cecCommand)))
+	})
+	if err != nil {
+		return err
+	}
+	if result != 1 {
+		return fmt.Errorf("Error in cec_transmit: %w", ErrNack)
+	}
+	return nil
 }
 
-// Destroy - destroy the cec connection
+// Destroy - close the connection and wait until it is freed. Destroy
+// must not be called from a callback or handler, use Close there
 func (c *Connection) Destroy() {
-	C.libcec_destroy(c.connection)
+	c.Close(context.Background())
+	<-c.Closed()
+}
+
+// Close - stop dispatching events, discard pending ones and start freeing
+// the connection. Close doesn't wait for running callbacks, so it can be
+// called from a callback or handler, the libcec connection is freed and
+// all event channels are closed once they have returned, see Closed.
+// Close waits for libcec calls that are still running until ctx is done
+// and can be called more than once
+func (c *Connection) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.closed = true
+		c.mu.Unlock()
+
+		if c.done != nil {
+			close(c.done)
+		}
+
+		closed := c.closeDoneChan()
+		go func() {
+			c.dispatch.Wait()
+			c.calls.Wait()
+			if c.connection != nil {
+				C.libcec_destroy(c.connection)
+			}
+			c.closeChannels()
+			close(closed)
+		}()
+	})
+
+	calls := make(chan struct{})
+	go func() {
+		c.calls.Wait()
+		close(calls)
+	}()
+
+	select {
+	case <-calls:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Closed - closed once a closed connection has been freed and its event
+// channels are closed
+func (c *Connection) Closed() <-chan struct{} {
+	return c.closeDoneChan()
+}
+
+func (c *Connection) closeDoneChan() chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closeDone == nil {
+		c.closeDone = make(chan struct{})
+	}
+	return c.closeDone
 }
 
 // PowerOn - power on the device with the given logical address
-func (c *Connection) PowerOn(address int) error {
-	if C.libcec_power_on_devices(c.connection, C.cec_logical_address(address)) != 0 {
-		return errors.New("Error in cec_power_on_devices")
+func (c *Connection) PowerOn(address LogicalAddress) error {
+	return c.PowerOnContext(context.Background(), address)
+}
+
+// PowerOnContext - like PowerOn, but gives up when ctx is done
+func (c *Connection) PowerOnContext(ctx context.Context, address LogicalAddress) error {
+	var result int
+	err := c.call(ctx, func() {
+		if c.hooks != nil {
+			if c.hooks.powerOn(address) {
+				result = 1
+			}
+			return
+		}
+		result = int(C.libcec_power_on_devices(c.connection, C.cec_logical_address(address)))
+	})
+	c.state.invalidate(address, fieldPowerStatus)
+	if err != nil {
+		return err
+	}
+	if result != 1 {
+		return fmt.Errorf("Error in cec_power_on_devices: %w", ErrNack)
 	}
 	return nil
 }
 
 // Standby - put the device with the given address in standby mode
-func (c *Connection) Standby(address int) error {
-	if C.libcec_standby_devices(c.connection, C.cec_logical_address(address)) != 0 {
-		return errors.New("Error in cec_standby_devices")
+func (c *Connection) Standby(address LogicalAddress) error {
+	return c.StandbyContext(context.Background(), address)
+}
+
+// StandbyContext - like Standby, but gives up when ctx is done
+func (c *Connection) StandbyContext(ctx context.Context, address LogicalAddress) error {
+	var result int
+	err := c.call(ctx, func() {
+		if c.hooks != nil {
+			if c.hooks.standby(address) {
+				result = 1
+			}
+			return
+		}
+		result = int(C.libcec_standby_devices(c.connection, C.cec_logical_address(address)))
+	})
+	c.state.invalidate(address, fieldPowerStatus)
+	if err != nil {
+		return err
+	}
+	if result != 1 {
+		return fmt.Errorf("Error in cec_standby_devices: %w", ErrNack)
 	}
 	return nil
 }
 
 // VolumeUp - send a volume up command to the amp if present
 func (c *Connection) VolumeUp() error {
-	if C.libcec_volume_up(c.connection, 1) != 0 {
+	return c.VolumeUpContext(context.Background())
+}
+
+// VolumeUpContext - like VolumeUp, but gives up when ctx is done
+func (c *Connection) VolumeUpContext(ctx context.Context) error {
+	var result int
+	err := c.call(ctx, func() {
+		result = int(C.libcec_volume_up(c.connection, 1))
+	})
+	if err != nil {
+		return err
+	}
+	if result != 0 {
 		return errors.New("Error in cec_volume_up")
 	}
 	return nil
@@ -222,7 +416,19 @@ func (c *Connection) VolumeUp() error {
 
 // VolumeDown - send a volume down command to the amp if present
 func (c *Connection) VolumeDown() error {
-	if C.libcec_volume_down(c.connection, 1) != 0 {
+	return c.VolumeDownContext(context.Background())
+}
+
+// VolumeDownContext - like VolumeDown, but gives up when ctx is done
+func (c *Connection) VolumeDownContext(ctx context.Context) error {
+	var result int
+	err := c.call(ctx, func() {
+		result = int(C.libcec_volume_down(c.connection, 1))
+	})
+	if err != nil {
+		return err
+	}
+	if result != 0 {
 		return errors.New("Error in cec_volume_down")
 	}
 	return nil
@@ -230,32 +436,105 @@ func (c *Connection) VolumeDown() error {
 
 // Mute - send a mute/unmute command to the amp if present
 func (c *Connection) Mute() error {
-	if C.libcec_mute_audio(c.connection, 1) != 0 {
+	return c.MuteContext(context.Background())
+}
+
+// MuteContext - like Mute, but gives up when ctx is done
+func (c *Connection) MuteContext(ctx context.Context) error {
+	var result int
+	err := c.call(ctx, func() {
+		result = int(C.libcec_mute_audio(c.connection, 1))
+	})
+	if err != nil {
+		return err
+	}
+	if result != 0 {
 		return errors.New("Error in cec_mute_audio")
 	}
 	return nil
 }
 
-// KeyPress - send a key press (down) command code to the given address
-func (c *Connection) KeyPress(address int, key int) error {
-	if C.libcec_send_keypress(c.connection, C.cec_logical_address(address), C.cec_user_control_code(key), 1) != 1 {
-		return errors.New("Error in cec_send_keypress")
+// KeyPress - send a key press (down) command code to the given address,
+// operands are sent along with keys that take them (see UICommand)
+func (c *Connection) KeyPress(address LogicalAddress, key KeyCode, operands ...byte) error {
+	return c.KeyPressContext(context.Background(), address, key, operands...)
+}
+
+// KeyPressContext - like KeyPress, but gives up when ctx is done
+func (c *Connection) KeyPressContext(ctx context.Context, address LogicalAddress, key KeyCode, operands ...byte) error {
+	if len(operands) > 0 {
+		// libcec only sends the bare key code
+		params := append([]byte{byte(key)}, operands...)
+		return c.transmit(ctx, address, opcodeUserControlPressed, params...)
+	}
+
+	var result int
+	err := c.call(ctx, func() {
+		if c.hooks != nil {
+			if c.hooks.keyPress(address, key) {
+				result = 1
+			}
+			return
+		}
+		result = int(C.libcec_send_keypress(c.connection, C.cec_logical_address(address), C.cec_user_control_code(key), 1))
+	})
+	if err != nil {
+		return err
+	}
+	if result != 1 {
+		return fmt.Errorf("Error in cec_send_keypress: %w", ErrNack)
 	}
 	return nil
 }
 
 // KeyRelease - send a key releas command to the given address
-func (c *Connection) KeyRelease(address int) error {
-	if C.libcec_send_key_release(c.connection, C.cec_logical_address(address), 1) != 1 {
-		return errors.New("Error in cec_send_key_release")
+func (c *Connection) KeyRelease(address LogicalAddress) error {
+	return c.KeyReleaseContext(context.Background(), address)
+}
+
+// KeyReleaseContext - like KeyRelease, but gives up when ctx is done
+func (c *Connection) KeyReleaseContext(ctx context.Context, address LogicalAddress) error {
+	var result int
+	err := c.call(ctx, func() {
+		if c.hooks != nil {
+			if c.hooks.keyRelease(address) {
+				result = 1
+			}
+			return
+		}
+		result = int(C.libcec_send_key_release(c.connection, C.cec_logical_address(address), 1))
+	})
+	if err != nil {
+		return err
+	}
+	if result != 1 {
+		return fmt.Errorf("Error in cec_send_key_release: %w", ErrNack)
 	}
 	return nil
 }
 
 // GetActiveDevices - returns an array of active devices
-func (c *Connection) GetActiveDevices() [16]bool {
+func (c *Connection) GetActiveDevices() ([16]bool, error) {
+	return c.GetActiveDevicesContext(context.Background())
+}
+
+// GetActiveDevicesContext - like GetActiveDevices, but gives up when ctx
+// is done
+func (c *Connection) GetActiveDevicesContext(ctx context.Context) ([16]bool, error) {
 	var devices [16]bool
-	result := C.libcec_get_active_devices(c.connection)
+	var result C.cec_logical_addresses
+	err := c.call(ctx, func() {
+		if c.hooks != nil {
+			for _, address := range c.hooks.activeDevices() {
+				result.addresses[address] = 1
+			}
+			return
+		}
+		result = C.libcec_get_active_devices(c.connection)
+	})
+	if err != nil {
+		return devices, err
+	}
 
 	for i := 0; i < 16; i++ {
 		if int(result.addresses[i]) > 0 {
@@ -263,92 +542,425 @@ func (c *Connection) GetActiveDevices() [16]bool {
 		}
 	}
 
-	return devices
+	return devices, nil
+}
+
+// isActiveDevice - check if libcec knows the device at the given address
+// to be present
+func (c *Connection) isActiveDevice(ctx context.Context, address LogicalAddress) (bool, error) {
+	var result int
+	err := c.call(ctx, func() {
+		if c.hooks != nil {
+			for _, active := range c.hooks.activeDevices() {
+				if active == address {
+					result = 1
+				}
+			}
+			return
+		}
+		result = int(C.libcec_is_active_device(c.connection, C.cec_logical_address(address)))
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result != 0, nil
 }
 
 // GetDeviceOSDName - get the OSD name of the specified device
-func (c *Connection) GetDeviceOSDName(address int) string {
+func (c *Connection) GetDeviceOSDName(address LogicalAddress) (string, error) {
+	return c.GetDeviceOSDNameContext(context.Background(), address)
+}
+
+// GetDeviceOSDNameContext - like GetDeviceOSDName, but gives up when ctx
+// is done
+func (c *Connection) GetDeviceOSDNameContext(ctx context.Context, address LogicalAddress) (string, error) {
+	if dev, ok := c.state.fresh(address, fieldOSDName); ok {
+		return dev.OSDName, nil
+	}
+
 	name := make([]byte, 14)
-	C.libcec_get_device_osd_name(c.connection, C.cec_logical_address(address), (*C.char)(unsafe.Pointer(&name[0])))
+	since := time.Now()
+	err := c.call(ctx, func() {
+		if c.hooks != nil {
+			copy(name, c.hooks.osdName(address))
+			return
+		}
+		C.libcec_get_device_osd_name(c.connection, C.cec_logical_address(address), (*C.char)(unsafe.Pointer(&name[0])))
+	})
+	if err != nil {
+		return "", err
+	}
 
-	return string(name)
+	// the name is NUL terminated and may be padded with spaces
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	osdName := strings.TrimSpace(string(name))
+	if osdName == "" {
+		return "", c.queryFailed(ctx, address, opcodeGiveOSDName, since)
+	}
+
+	c.state.update(address, fieldOSDName, func(d *Device) { d.OSDName = osdName })
+	return osdName, nil
 }
 
 // IsActiveSource - check if the device at the given address is the active source
-func (c *Connection) IsActiveSource(address int) bool {
-	result := C.libcec_is_active_source(c.connection, C.cec_logical_address(address))
+func (c *Connection) IsActiveSource(address LogicalAddress) (bool, error) {
+	return c.IsActiveSourceContext(context.Background(), address)
+}
 
-	if int(result) != 0 {
-		return true
+// IsActiveSourceContext - like IsActiveSource, but gives up when ctx is
+// done
+func (c *Connection) IsActiveSourceContext(ctx context.Context, address LogicalAddress) (bool, error) {
+	var result int
+	err := c.call(ctx, func() {
+		if c.hooks != nil {
+			if c.hooks.activeSource(address) {
+				result = 1
+			}
+			return
+		}
+		result = int(C.libcec_is_active_source(c.connection, C.cec_logical_address(address)))
+	})
+	if err != nil {
+		return false, err
 	}
 
-	return false
+	if result != 0 {
+		c.setStateActiveSource(address)
+	} else {
+		c.state.update(address, fieldActiveSource, func(d *Device) { d.ActiveSource = false })
+	}
+	return result != 0, nil
 }
 
 // SetActiveSource
-func (c *Connection) SetActiveSource(device_type int) bool {
-	result := C.libcec_set_active_source(c.connection, C.cec_device_type(device_type))
+func (c *Connection) SetActiveSource(device_type DeviceType) bool {
+	ok, _ := c.SetActiveSourceContext(context.Background(), device_type)
+	return ok
+}
 
-	if int(result) != 0 {
-		return true
+// SetActiveSourceContext - like SetActiveSource, but gives up when ctx is
+// done
+func (c *Connection) SetActiveSourceContext(ctx context.Context, device_type DeviceType) (bool, error) {
+	var result int
+	err := c.call(ctx, func() {
+		result = int(C.libcec_set_active_source(c.connection, C.cec_device_type(device_type)))
+	})
+	if err != nil {
+		return false, err
 	}
 
-	return false
+	return result != 0, nil
 }
 
 // RescanDevices
 func (c *Connection) RescanDevices() {
-	C.libcec_rescan_devices(c.connection)
+	c.RescanDevicesContext(context.Background())
+}
+
+// RescanDevicesContext - like RescanDevices, but gives up when ctx is done
+func (c *Connection) RescanDevicesContext(ctx context.Context) error {
+	return c.call(ctx, func() {
+		C.libcec_rescan_devices(c.connection)
+	})
 }
 
 // GetDeviceVendorID - Get the Vendor-ID of the device at the given address
-func (c *Connection) GetDeviceVendorID(address int) uint64 {
-	result := C.libcec_get_device_vendor_id(c.connection, C.cec_logical_address(address))
+func (c *Connection) GetDeviceVendorID(address LogicalAddress) (uint64, error) {
+	return c.GetDeviceVendorIDContext(context.Background(), address)
+}
+
+// GetDeviceVendorIDContext - like GetDeviceVendorID, but gives up when ctx
+// is done
+func (c *Connection) GetDeviceVendorIDContext(ctx context.Context, address LogicalAddress) (uint64, error) {
+	if dev, ok := c.state.fresh(address, fieldVendorID); ok {
+		return dev.VendorID, nil
+	}
+
+	var result uint64
+	since := time.Now()
+	err := c.call(ctx, func() {
+		result = uint64(C.libcec_get_device_vendor_id(c.connection, C.cec_logical_address(address)))
+	})
+	if err != nil {
+		return 0, err
+	}
+	if result == C.CEC_VENDOR_UNKNOWN {
+		return 0, c.queryFailed(ctx, address, opcodeGiveDeviceVendorID, since)
+	}
 
-	return uint64(result)
+	c.state.update(address, fieldVendorID, func(d *Device) {
+		d.VendorID = result
+		d.Vendor = GetVendorByID(result)
+	})
+	return result, nil
 }
 
 // GetDevicePhysicalAddress - Get the physical address of the device at
 // the given logical address
-func (c *Connection) GetDevicePhysicalAddress(address int) string {
-	result := C.libcec_get_device_physical_address(c.connection, C.cec_logical_address(address))
+func (c *Connection) GetDevicePhysicalAddress(address LogicalAddress) (PhysicalAddress, error) {
+	return c.GetDevicePhysicalAddressContext(context.Background(), address)
+}
 
-	return fmt.Sprintf("%x.%x.%x.%x", (uint(result)>>12)&0xf, (uint(result)>>8)&0xf, (uint(result)>>4)&0xf, uint(result)&0xf)
+// GetDevicePhysicalAddressContext - like GetDevicePhysicalAddress, but
+// gives up when ctx is done
+func (c *Connection) GetDevicePhysicalAddressContext(ctx context.Context, address LogicalAddress) (PhysicalAddress, error) {
+	if dev, ok := c.state.fresh(address, fieldPhysicalAddress); ok {
+		return dev.PhysicalAddress, nil
+	}
+
+	var result PhysicalAddress
+	since := time.Now()
+	err := c.call(ctx, func() {
+		result = PhysicalAddress(C.libcec_get_device_physical_address(c.connection, C.cec_logical_address(address)))
+	})
+	if err != nil {
+		return InvalidPhysicalAddress, err
+	}
+	if result == InvalidPhysicalAddress {
+		return result, c.queryFailed(ctx, address, opcodeGivePhysicalAddress, since)
+	}
+
+	c.state.update(address, fieldPhysicalAddress, func(d *Device) { d.PhysicalAddress = result })
+	return result, nil
 }
 
-// Poll device - poll the device at
-// the given logical address
-func (c *Connection) PollDevice(address int) string {
-	result := C.libcec_poll_device(c.connection, C.cec_logical_address(address))
+// PollDevice - poll the device at the given logical address, returns
+// true when the poll was acknowledged
+func (c *Connection) PollDevice(address LogicalAddress) (bool, error) {
+	return c.PollDeviceContext(context.Background(), address)
+}
 
-	return fmt.Sprintf("%T: %+v", result, result)
+// PollDeviceContext - like PollDevice, but gives up when ctx is done
+func (c *Connection) PollDeviceContext(ctx context.Context, address LogicalAddress) (bool, error) {
+	var result int
+	err := c.call(ctx, func() {
+		if c.hooks != nil {
+			if c.hooks.poll(address) {
+				result = 1
+			}
+			return
+		}
+		result = int(C.libcec_poll_device(c.connection, C.cec_logical_address(address)))
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result == 1, nil
 }
 
 //extern DECLSPEC int libcec_set_osd_string(libcec_connection_t connection, cec_namespace cec_logical_address ilogicaladdress, cec_namespace cec_display_control duration, const char* strmessage);
-func (c *Connection) SetOSDString(address int, str string) error {
-	msg := []byte(str)
-	if C.libcec_set_osd_string(c.connection, C.cec_logical_address(address), C.cec_display_control(1), (*C.char)(unsafe.Pointer(&msg[0]))) != 0 {
-		return errors.New("Error in cec_set_osd_string")
+func (c *Connection) SetOSDString(address LogicalAddress, str string) error {
+	return c.SetOSDStringContext(context.Background(), address, str)
+}
+
+// SetOSDStringContext - like SetOSDString, but gives up when ctx is done
+func (c *Connection) SetOSDStringContext(ctx context.Context, address LogicalAddress, str string) error {
+	var result int
+	err := c.call(ctx, func() {
+		msg := C.CString(str)
+		defer C.free(unsafe.Pointer(msg))
+		result = int(C.libcec_set_osd_string(c.connection, C.cec_logical_address(address), C.cec_display_control(1), msg))
+	})
+	if err != nil {
+		return err
+	}
+	if result != 1 {
+		return fmt.Errorf("Error in cec_set_osd_string: %w", ErrNack)
 	}
 	return nil
 }
 
 // GetDevicePowerStatus - Get the power status of the device at the
 // given address
-func (c *Connection) GetDevicePowerStatus(address int) string {
-	result := C.libcec_get_device_power_status(c.connection, C.cec_logical_address(address))
-
-	// C.CEC_POWER_STATUS_UNKNOWN == error
-
-	if int(result) == C.CEC_POWER_STATUS_ON {
-		return "on"
-	} else if int(result) == C.CEC_POWER_STATUS_STANDBY {
-		return "standby"
-	} else if int(result) == C.CEC_POWER_STATUS_IN_TRANSITION_STANDBY_TO_ON {
-		return "starting"
-	} else if int(result) == C.CEC_POWER_STATUS_IN_TRANSITION_ON_TO_STANDBY {
-		return "shutting down"
-	} else {
-		return ""
+func (c *Connection) GetDevicePowerStatus(address LogicalAddress) (PowerStatus, error) {
+	return c.GetDevicePowerStatusContext(context.Background(), address)
+}
+
+// GetDevicePowerStatusContext - like GetDevicePowerStatus, but gives up
+// when ctx is done
+func (c *Connection) GetDevicePowerStatusContext(ctx context.Context, address LogicalAddress) (PowerStatus, error) {
+	if dev, ok := c.state.fresh(address, fieldPowerStatus); ok {
+		return dev.PowerStatus, nil
+	}
+	return c.queryPowerStatus(ctx, address)
+}
+
+// queryPowerStatus - get the power status from libcec, bypassing the cache
+func (c *Connection) queryPowerStatus(ctx context.Context, address LogicalAddress) (PowerStatus, error) {
+	var result int
+	since := time.Now()
+	err := c.call(ctx, func() {
+		if c.hooks != nil {
+			result = int(c.hooks.powerStatus(address))
+			return
+		}
+		result = int(C.libcec_get_device_power_status(c.connection, C.cec_logical_address(address)))
+	})
+	if err != nil {
+		return PowerStatusUnknown, err
+	}
+
+	status := PowerStatus(result)
+	if status == PowerStatusUnknown {
+		return status, c.queryFailed(ctx, address, opcodeGivePowerStatus, since)
+	}
+
+	c.state.update(address, fieldPowerStatus, func(d *Device) { d.PowerStatus = status })
+	return status, nil
+}
+
+// GetDeviceCECVersion - get the CEC version of the device at the given
+// address
+func (c *Connection) GetDeviceCECVersion(address LogicalAddress) (CECVersion, error) {
+	return c.GetDeviceCECVersionContext(context.Background(), address)
+}
+
+// GetDeviceCECVersionContext - like GetDeviceCECVersion, but gives up when
+// ctx is done
+func (c *Connection) GetDeviceCECVersionContext(ctx context.Context, address LogicalAddress) (CECVersion, error) {
+	if dev, ok := c.state.fresh(address, fieldCECVersion); ok {
+		return dev.CECVersion, nil
+	}
+
+	var result CECVersion
+	since := time.Now()
+	err := c.call(ctx, func() {
+		result = CECVersion(C.libcec_get_device_cec_version(c.connection, C.cec_logical_address(address)))
+	})
+	if err != nil {
+		return CECVersionUnknown, err
+	}
+	if result == CECVersionUnknown {
+		return result, c.queryFailed(ctx, address, opcodeGetCECVersion, since)
 	}
+
+	c.state.update(address, fieldCECVersion, func(d *Device) { d.CECVersion = result })
+	return result, nil
+}
+
+// GetDeviceMenuLanguage - get the ISO 639-2 menu language of the device at
+// the given address
+func (c *Connection) GetDeviceMenuLanguage(address LogicalAddress) (string, error) {
+	return c.GetDeviceMenuLanguageContext(context.Background(), address)
+}
+
+// GetDeviceMenuLanguageContext - like GetDeviceMenuLanguage, but gives up
+// when ctx is done
+func (c *Connection) GetDeviceMenuLanguageContext(ctx context.Context, address LogicalAddress) (string, error) {
+	if dev, ok := c.state.fresh(address, fieldMenuLanguage); ok {
+		return dev.MenuLanguage, nil
+	}
+
+	language := make([]byte, 4)
+	since := time.Now()
+	err := c.call(ctx, func() {
+		C.libcec_get_device_menu_language(c.connection, C.cec_logical_address(address), (*C.char)(unsafe.Pointer(&language[0])))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if i := bytes.IndexByte(language, 0); i >= 0 {
+		language = language[:i]
+	}
+	// libcec reports "???" when the language is not known
+	if len(language) != 3 || string(language) == "???" {
+		return "", c.queryFailed(ctx, address, opcodeGetMenuLanguage, since)
+	}
+
+	c.state.update(address, fieldMenuLanguage, func(d *Device) { d.MenuLanguage = string(language) })
+	return string(language), nil
+}
+
+// getOwnAddresses - get all logical addresses claimed by our adapter
+func (c *Connection) getOwnAddresses(ctx context.Context) ([16]bool, error) {
+	var own [16]bool
+	var result C.cec_logical_addresses
+	err := c.call(ctx, func() {
+		if c.hooks != nil {
+			result.addresses[c.hooks.ownAddress] = 1
+			return
+		}
+		result = C.libcec_get_logical_addresses(c.connection)
+	})
+	if err != nil {
+		return own, err
+	}
+
+	for i := 0; i < 16; i++ {
+		if int(result.addresses[i]) > 0 {
+			own[i] = true
+		}
+	}
+
+	return own, nil
+}
+
+// getOwnAddress - get the primary logical address of our adapter
+func (c *Connection) getOwnAddress(ctx context.Context) (LogicalAddress, error) {
+	var result LogicalAddress
+	err := c.call(ctx, func() {
+		if c.hooks != nil {
+			result = c.hooks.ownAddress
+			return
+		}
+		result = LogicalAddress(C.libcec_get_logical_addresses(c.connection).primary)
+	})
+	if err != nil {
+		return Unregistered, err
+	}
+	if !result.IsValid() {
+		return Unregistered, ErrNoAdapter
+	}
+
+	return result, nil
+}
+
+// transmit - send a message with the given opcode and parameters from our
+// own address to destination
+func (c *Connection) transmit(ctx context.Context, destination LogicalAddress, opcode int, params ...byte) error {
+	initiator, err := c.getOwnAddress(ctx)
+	if err != nil {
+		return err
+	}
+
+	var cecCommand C.cec_command
+	cecCommand.initiator = C.cec_logical_address(initiator)
+	cecCommand.destination = C.cec_logical_address(destination)
+	cecCommand.opcode_set = 1
+	cecCommand.opcode = C.cec_opcode(opcode)
+	cecCommand.parameters.size = C.uint8_t(len(params))
+	for i, param := range params {
+		cecCommand.parameters.data[i] = C.uint8_t(param)
+	}
+
+	var result int
+	err = c.call(ctx, func() {
+		if c.hooks != nil {
+			msg := &Command{
+				Initiator:   initiator,
+				Destination: destination,
+				Opcode:      opcode,
+				Parameters:  DataPacket{Data: params, Size: len(params)},
+				OpcodeSet:   1,
+				Operation:   opcodes[opcode],
+			}
+			if c.hooks.transmit(msg) {
+				result = 1
+			}
+			return
+		}
+		result = int(C.libcec_transmit(c.connection, (*C.cec_command)(&cecCommand)))
+	})
+	if err != nil {
+		return err
+	}
+	if result != 1 {
+		return fmt.Errorf("Error transmitting %s: %w", opcodes[opcode], ErrNack)
+	}
+	return nil
 }
@@ -0,0 +1,305 @@
+package cec
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestConnection() *Connection {
+	return &Connection{
+		Commands:            make(chan *Command, 1),
+		KeyPresses:          make(chan *KeyPress, 1),
+		Messages:            make(chan string, 1),
+		SourceActivations:   make(chan *SourceActivation, 1),
+		MenuActivations:     make(chan bool, 1),
+		ActiveSourceChanges: make(chan *ActiveSourceInfo, 1),
+		done:                make(chan struct{}),
+	}
+}
+
+func TestCloseChannels(t *testing.T) {
+	c := newTestConnection()
+	c.Messages = nil
+
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	<-c.Closed()
+	if _, ok := <-c.Commands; ok {
+		t.Error("Commands not closed")
+	}
+	if _, ok := <-c.KeyPresses; ok {
+		t.Error("KeyPresses not closed")
+	}
+	if _, ok := <-c.SourceActivations; ok {
+		t.Error("SourceActivations not closed")
+	}
+	if _, ok := <-c.MenuActivations; ok {
+		t.Error("MenuActivations not closed")
+	}
+	if _, ok := <-c.ActiveSourceChanges; ok {
+		t.Error("ActiveSourceChanges not closed")
+	}
+
+	// a second Close is a no-op
+	if err := c.Close(context.Background()); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+	if !c.closed {
+		t.Error("connection not marked closed")
+	}
+}
+
+func TestCloseUnopened(t *testing.T) {
+	c := new(Connection)
+	if err := c.Close(context.Background()); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+	if err := c.call(context.Background(), func() {}); !errors.Is(err, ErrClosed) {
+		t.Errorf("call after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestCloseDuringCallback(t *testing.T) {
+	c := newTestConnection()
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	c.OnKeyPress = func(KeyPress) bool {
+		close(entered)
+		<-release
+		return false
+	}
+	go c.handleKeyPress(&KeyPress{KeyCode: KeySelect})
+	<-entered
+
+	// Close doesn't wait for the callback, the shutdown does
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatalf("Close() = %v while a callback runs", err)
+	}
+	select {
+	case <-c.Closed():
+		t.Fatal("connection freed while a callback runs")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// events arriving during the shutdown are dropped
+	c.handleKeyPress(&KeyPress{KeyCode: KeyUp})
+
+	close(release)
+	select {
+	case <-c.Closed():
+	case <-time.After(time.Second):
+		t.Fatal("connection not freed after the callback returned")
+	}
+	var got []KeyCode
+	for k := range c.KeyPresses {
+		got = append(got, k.KeyCode)
+	}
+	if len(got) > 1 || (len(got) == 1 && got[0] != KeySelect) {
+		t.Errorf("KeyPresses got %v", got)
+	}
+}
+
+func TestCloseWaitsForCalls(t *testing.T) {
+	c := newTestConnection()
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	go c.call(context.Background(), func() {
+		close(entered)
+		<-release
+	})
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close() = %v while a libcec call runs, want the deadline", err)
+	}
+
+	close(release)
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	<-c.Closed()
+}
+
+func TestCloseFromCallback(t *testing.T) {
+	c := newTestConnection()
+	closed := make(chan error, 1)
+	c.OnMenuStateChanged = func(MenuState) bool {
+		closed <- c.Close(context.Background())
+		return true
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.handleMenuState(MenuStateActivated)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close() from a callback deadlocked")
+	}
+	if err := <-closed; err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+	select {
+	case <-c.Closed():
+	case <-time.After(time.Second):
+		t.Fatal("connection not freed after the callback returned")
+	}
+	// the accepted change is still delivered, then the channel is closed
+	for range c.MenuActivations {
+	}
+}
+
+func TestCloseFromListener(t *testing.T) {
+	c := newTestConnection()
+	c.listen(func(*Command) { c.Close(context.Background()) })
+
+	done := make(chan struct{})
+	go func() {
+		if c.enterCallback() {
+			defer c.leaveCallback("commandReceived")
+			c.commandReceived(command(TV, Broadcast, opcodeStandby))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close() from a listener deadlocked")
+	}
+	select {
+	case <-c.Closed():
+	case <-time.After(time.Second):
+		t.Fatal("connection not freed after the listener returned")
+	}
+}
+
+// fakeBus - stands in for libcec, records transmitted messages and lets
+// tests answer them
+type fakeBus struct {
+	c *Connection
+
+	mu      sync.Mutex
+	sent    []*Command
+	sentAt  []time.Time
+	power   map[LogicalAddress]PowerStatus
+	present map[LogicalAddress]bool
+	nack    map[int]bool
+	answer  func(msg *Command)
+}
+
+func newFakeBus(own LogicalAddress) *fakeBus {
+	b := &fakeBus{
+		power:   make(map[LogicalAddress]PowerStatus),
+		present: make(map[LogicalAddress]bool),
+		nack:    make(map[int]bool),
+	}
+	b.c = &Connection{done: make(chan struct{})}
+	b.c.state.ttl = DefaultCacheTTL
+	b.c.activeSource.historySize = DefaultActiveSourceHistorySize
+	b.c.hooks = &libcecHooks{
+		ownAddress: own,
+		transmit:   b.transmit,
+		powerStatus: func(address LogicalAddress) PowerStatus {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if status, ok := b.power[address]; ok {
+				return status
+			}
+			return PowerStatusStandby
+		},
+		activeDevices: func() []LogicalAddress {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			var present []LogicalAddress
+			for address := TV; address < Broadcast; address++ {
+				if b.present[address] {
+					present = append(present, address)
+				}
+			}
+			return present
+		},
+		poll: func(address LogicalAddress) bool {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			return b.present[address]
+		},
+		activeSource: func(LogicalAddress) bool { return false },
+		osdName:      func(LogicalAddress) string { return "" },
+		powerOn: func(address LogicalAddress) bool {
+			b.setPower(address, PowerStatusOn)
+			return true
+		},
+		standby: func(address LogicalAddress) bool {
+			return b.transmit(command(own, address, opcodeStandby))
+		},
+		keyPress: func(address LogicalAddress, key KeyCode) bool {
+			return b.transmit(command(own, address, opcodeUserControlPressed, byte(key)))
+		},
+		keyRelease: func(address LogicalAddress) bool {
+			return b.transmit(command(own, address, opcodeUserControlRelease))
+		},
+	}
+	return b
+}
+
+// transmit - record msg and let the test answer it, returns the ack
+func (b *fakeBus) transmit(msg *Command) bool {
+	b.mu.Lock()
+	b.sent = append(b.sent, msg)
+	b.sentAt = append(b.sentAt, time.Now())
+	answer := b.answer
+	ack := !b.nack[msg.Opcode]
+	b.mu.Unlock()
+	if answer != nil {
+		// answers arrive on libcec's thread, after the transmit
+		go answer(msg)
+	}
+	return ack
+}
+
+func (b *fakeBus) setPresent(address LogicalAddress, present bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.present[address] = present
+}
+
+// receive - deliver a message from the bus as libcec would
+func (b *fakeBus) receive(msg *Command) {
+	if b.c.enterCallback() {
+		defer b.c.leaveCallback("commandReceived")
+		b.c.commandReceived(msg)
+	}
+}
+
+func (b *fakeBus) setPower(address LogicalAddress, status PowerStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.power[address] = status
+}
+
+func (b *fakeBus) onTransmit(fn func(msg *Command)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.answer = fn
+}
+
+// opcodes - the opcodes sent so far
+func (b *fakeBus) opcodes() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []string
+	for _, msg := range b.sent {
+		out = append(out, opcodes[msg.Opcode])
+	}
+	return out
+}
@@ -0,0 +1,286 @@
+package cec
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// LircVersion - version reported to lircd clients
+const LircVersion = "0.10.1"
+
+// LircServer - serves the lircd socket protocol, key presses are sent to
+// every client as "<code> <repeat> <button> <remote>" lines with the key
+// name as button and the initiating device as remote
+type LircServer struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	clients map[*lircClient]bool
+	remotes map[string]bool
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+type lircClient struct {
+	conn net.Conn
+	out  chan string
+}
+
+// ListenLirc - listen on a unix socket at path, e.g. /run/lirc/lircd. A
+// stale socket file nobody accepts connections on is removed, a socket in
+// use by another server is left alone
+func ListenLirc(path string) (*LircServer, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("Socket %s is in use", path)
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			os.Remove(path)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// lircd sockets are used by any local user
+	if err := os.Chmod(path, 0o666); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return NewLircServer(ln), nil
+}
+
+// NewLircServer - create a server accepting clients on ln, Serve has to be
+// called to accept them
+func NewLircServer(ln net.Listener) *LircServer {
+	return &LircServer{
+		ln:      ln,
+		clients: make(map[*lircClient]bool),
+		remotes: make(map[string]bool),
+	}
+}
+
+// Serve - accept clients until the server is closed
+func (s *LircServer) Serve() error {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		c := &lircClient{conn: conn, out: make(chan string, 64)}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		s.clients[c] = true
+		s.wg.Add(2)
+		s.mu.Unlock()
+
+		go s.write(c)
+		go s.read(c)
+	}
+}
+
+// Run - send the key events from in to the clients until in is closed or
+// ctx is done
+func (s *LircServer) Run(ctx context.Context, in <-chan KeyEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-in:
+			if !ok {
+				return
+			}
+			s.Send(ev)
+		}
+	}
+}
+
+// Send - send a key press or repeat to all clients, other events are
+// ignored
+func (s *LircServer) Send(ev KeyEvent) {
+	line, remote, ok := lircLine(ev)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remotes[remote] = true
+	for c := range s.clients {
+		select {
+		case c.out <- line:
+		default:
+			slog.Warn("Dropping slow lirc client", "remote", c.conn.RemoteAddr())
+			s.drop(c)
+		}
+	}
+}
+
+// Close - stop accepting clients and disconnect all clients
+func (s *LircServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	err := s.ln.Close()
+	for c := range s.clients {
+		s.drop(c)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// drop - disconnect a client, must be called with mu held
+func (s *LircServer) drop(c *lircClient) {
+	if !s.clients[c] {
+		return
+	}
+	delete(s.clients, c)
+	close(c.out)
+	c.conn.Close()
+}
+
+func (s *LircServer) write(c *lircClient) {
+	defer s.wg.Done()
+
+	for line := range c.out {
+		if _, err := c.conn.Write([]byte(line)); err != nil {
+			s.mu.Lock()
+			s.drop(c)
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *LircServer) read(c *lircClient) {
+	defer s.wg.Done()
+
+	scanner := bufio.NewScanner(c.conn)
+	for scanner.Scan() {
+		command := strings.TrimSpace(scanner.Text())
+		if command == "" {
+			continue
+		}
+		reply := s.reply(command)
+
+		s.mu.Lock()
+		if s.clients[c] {
+			select {
+			case c.out <- reply:
+			default:
+				s.drop(c)
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	s.drop(c)
+	s.mu.Unlock()
+}
+
+// reply - the reply packet for a client command
+func (s *LircServer) reply(command string) string {
+	fields := strings.Fields(command)
+	switch strings.ToUpper(fields[0]) {
+	case "VERSION":
+		return lircReply(command, true, LircVersion)
+	case "LIST":
+		if len(fields) == 1 {
+			s.mu.Lock()
+			remotes := make([]string, 0, len(s.remotes))
+			for remote := range s.remotes {
+				remotes = append(remotes, remote)
+			}
+			s.mu.Unlock()
+			sort.Strings(remotes)
+			return lircReply(command, true, remotes...)
+		}
+		if _, err := ParseLogicalAddress(fields[1]); err != nil {
+			return lircReply(command, false, fmt.Sprintf("unknown remote: \"%s\"", fields[1]))
+		}
+		if len(fields) == 3 {
+			key, err := ParseKeyCode(fields[2])
+			if err != nil {
+				return lircReply(command, false, fmt.Sprintf("unknown command: \"%s\"", fields[2]))
+			}
+			return lircReply(command, true, fmt.Sprintf("%016x %s", int(key), key))
+		}
+		var codes []string
+		for code := KeyCode(0); code <= 0xFF; code++ {
+			if name, ok := keyNames[code]; ok {
+				codes = append(codes, fmt.Sprintf("%016x %s", int(code), name))
+			}
+		}
+		return lircReply(command, true, codes...)
+	default:
+		return lircReply(command, false, fmt.Sprintf("unknown directive: \"%s\"", fields[0]))
+	}
+}
+
+// lircReply - a reply packet in the lircd format
+func lircReply(command string, success bool, data ...string) string {
+	var b strings.Builder
+	b.WriteString("BEGIN\n")
+	b.WriteString(command + "\n")
+	if success {
+		b.WriteString("SUCCESS\n")
+	} else {
+		b.WriteString("ERROR\n")
+	}
+	if len(data) > 0 {
+		fmt.Fprintf(&b, "DATA\n%d\n", len(data))
+		for _, line := range data {
+			b.WriteString(line + "\n")
+		}
+	}
+	b.WriteString("END\n")
+	return b.String()
+}
+
+// lircLine - the lircd line of a key press or repeat and its remote name
+func lircLine(ev KeyEvent) (string, string, bool) {
+	var info KeyInfo
+	repeat := 0
+	switch ev := ev.(type) {
+	case KeyDownEvent:
+		info = ev.KeyInfo
+	case KeyRepeatEvent:
+		info, repeat = ev.KeyInfo, ev.Count
+	default:
+		return "", "", false
+	}
+	if info.Vendor {
+		return "", "", false
+	}
+	if repeat > 0xFF {
+		repeat = 0xFF
+	}
+
+	remote := removeSeparators(info.Initiator.String())
+	return fmt.Sprintf("%016x %02x %s %s\n", int(info.Key), repeat, info.Key, remote), remote, true
+}
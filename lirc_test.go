@@ -0,0 +1,114 @@
+package cec
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLircLine(t *testing.T) {
+	tests := []struct {
+		ev   KeyEvent
+		want string
+	}{
+		{KeyDownEvent{KeyInfo: KeyInfo{Initiator: TV, Key: KeyChannelUp}}, "0000000000000030 00 ChannelUp TV\n"},
+		{KeyRepeatEvent{KeyInfo: KeyInfo{Initiator: Playback1, Key: KeyRed}, Count: 3}, "0000000000000072 03 Red Playback\n"},
+		{KeyUpEvent{KeyInfo: KeyInfo{Initiator: TV, Key: KeyRed}}, ""},
+		{KeyDownEvent{KeyInfo: KeyInfo{Initiator: TV, Key: KeyRed, Vendor: true}}, ""},
+	}
+
+	for _, tt := range tests {
+		if got, _, _ := lircLine(tt.ev); got != tt.want {
+			t.Errorf("lircLine(%+v) = %q, want %q", tt.ev, got, tt.want)
+		}
+	}
+}
+
+func TestLircServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lircd")
+	s, err := ListenLirc(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	go s.Serve()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+
+	readPacket := func() string {
+		var lines []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading reply: %v (got %q)", err, lines)
+			}
+			lines = append(lines, line)
+			if line == "END\n" {
+				return strings.Join(lines, "")
+			}
+		}
+	}
+
+	conn.Write([]byte("VERSION\n"))
+	if got, want := readPacket(), "BEGIN\nVERSION\nSUCCESS\nDATA\n1\n"+LircVersion+"\nEND\n"; got != want {
+		t.Errorf("VERSION reply = %q, want %q", got, want)
+	}
+
+	conn.Write([]byte("LIST TV Select\n"))
+	if got, want := readPacket(), "BEGIN\nLIST TV Select\nSUCCESS\nDATA\n1\n0000000000000000 Select\nEND\n"; got != want {
+		t.Errorf("LIST reply = %q, want %q", got, want)
+	}
+
+	conn.Write([]byte("SEND_ONCE TV Select\n"))
+	if got := readPacket(); !strings.Contains(got, "ERROR\n") {
+		t.Errorf("SEND_ONCE reply = %q, want an error", got)
+	}
+
+	// the client is registered once it has been answered
+	s.Send(KeyDownEvent{KeyInfo: KeyInfo{Initiator: TV, Key: KeyPlay}})
+	line, err := r.ReadString('\n')
+	if want := "0000000000000044 00 Play TV\n"; err != nil || line != want {
+		t.Errorf("key line = %q, %v, want %q", line, err, want)
+	}
+
+	conn.Write([]byte("LIST\n"))
+	if got, want := readPacket(), "BEGIN\nLIST\nSUCCESS\nDATA\n1\nTV\nEND\n"; got != want {
+		t.Errorf("LIST reply = %q, want %q", got, want)
+	}
+}
+
+func TestListenLircExistingSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lircd")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a live socket belongs to another server
+	if s, err := ListenLirc(path); err == nil {
+		s.Close()
+		t.Fatal("ListenLirc() took over a socket in use")
+	}
+	if _, err := os.Lstat(path); err != nil {
+		t.Fatalf("socket in use was removed: %v", err)
+	}
+
+	// a stale one is replaced
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	s, err := ListenLirc(path)
+	if err != nil {
+		t.Fatalf("ListenLirc() on a stale socket = %v", err)
+	}
+	s.Close()
+}
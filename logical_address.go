@@ -0,0 +1,154 @@
+package cec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LogicalAddress - logical address of a device on the CEC bus
+type LogicalAddress int
+
+const (
+	TV           LogicalAddress = 0
+	Recording1   LogicalAddress = 1
+	Recording2   LogicalAddress = 2
+	Tuner1       LogicalAddress = 3
+	Playback1    LogicalAddress = 4
+	AudioSystem  LogicalAddress = 5
+	Tuner2       LogicalAddress = 6
+	Tuner3       LogicalAddress = 7
+	Playback2    LogicalAddress = 8
+	Recording3   LogicalAddress = 9
+	Tuner4       LogicalAddress = 10
+	Playback3    LogicalAddress = 11
+	Reserved1    LogicalAddress = 12
+	Reserved2    LogicalAddress = 13
+	Specific     LogicalAddress = 14
+	Unregistered LogicalAddress = 15
+	// Broadcast - as a destination address 15 addresses all devices
+	Broadcast LogicalAddress = 15
+)
+
+// DeviceType - type of a CEC device, determines which logical addresses
+// it can claim
+type DeviceType int
+
+const (
+	DeviceTypeUnknown     DeviceType = -1
+	DeviceTypeTV          DeviceType = 0
+	DeviceTypeRecording   DeviceType = 1
+	DeviceTypeReserved    DeviceType = 2
+	DeviceTypeTuner       DeviceType = 3
+	DeviceTypePlayback    DeviceType = 4
+	DeviceTypeAudioSystem DeviceType = 5
+)
+
+var deviceTypeNames = map[DeviceType]string{
+	DeviceTypeTV:          "TV",
+	DeviceTypeRecording:   "Recording device",
+	DeviceTypeReserved:    "Reserved",
+	DeviceTypeTuner:       "Tuner",
+	DeviceTypePlayback:    "Playback device",
+	DeviceTypeAudioSystem: "Audio system",
+}
+
+var logicalDeviceTypes = [16]DeviceType{
+	DeviceTypeTV, DeviceTypeRecording, DeviceTypeRecording, DeviceTypeTuner,
+	DeviceTypePlayback, DeviceTypeAudioSystem, DeviceTypeTuner, DeviceTypeTuner,
+	DeviceTypePlayback, DeviceTypeRecording, DeviceTypeTuner, DeviceTypePlayback,
+	DeviceTypeReserved, DeviceTypeReserved, DeviceTypeUnknown, DeviceTypeUnknown}
+
+// logicalAliases - additional names accepted by ParseLogicalAddress, after
+// removing separators and converting to lower case
+var logicalAliases = map[string]LogicalAddress{
+	"recording1": Recording1, "recorder": Recording1, "tuner1": Tuner1,
+	"playback1": Playback1, "player": Playback1, "audiosystem": AudioSystem,
+	"amp": AudioSystem, "reserved1": Reserved1, "specific": Specific,
+	"specificuse": Specific, "unregistered": Unregistered,
+}
+
+func (a LogicalAddress) String() string {
+	if !a.IsValid() {
+		return fmt.Sprintf("LogicalAddress(%d)", int(a))
+	}
+	return logicalNames[a]
+}
+
+// IsValid - check that the address is in the range 0-15
+func (a LogicalAddress) IsValid() bool {
+	return a >= 0 && a <= 15
+}
+
+// DeviceType - the type of device that uses this address
+func (a LogicalAddress) DeviceType() DeviceType {
+	if !a.IsValid() {
+		return DeviceTypeUnknown
+	}
+	return logicalDeviceTypes[a]
+}
+
+// ParseLogicalAddress - parse a logical address given by name ("TV",
+// "Playback 2", "audio-system", ...) or number ("5", "0x5"), ignoring case
+// and separators. Names without a number also accept a trailing 1 ("TV1",
+// "Audio1") as GetLogicalAddressByName always did
+func ParseLogicalAddress(name string) (LogicalAddress, error) {
+	if n, err := parseLogicalNumber(strings.TrimSpace(name)); err == nil {
+		if !LogicalAddress(n).IsValid() {
+			return Unregistered, fmt.Errorf("Logical address %q out of range", name)
+		}
+		return LogicalAddress(n), nil
+	}
+
+	key := strings.ToLower(removeSeparators(name))
+	if key == "" {
+		return Unregistered, fmt.Errorf("Empty logical address")
+	}
+
+	for i, logicalName := range logicalNames {
+		logicalName = strings.ToLower(logicalName)
+		if logicalName == key {
+			return LogicalAddress(i), nil
+		}
+		if !strings.ContainsAny(logicalName, "0123456789") && logicalName+"1" == key {
+			return LogicalAddress(i), nil
+		}
+	}
+	if address, ok := logicalAliases[key]; ok {
+		return address, nil
+	}
+
+	return Unregistered, fmt.Errorf("Unknown logical address %q", name)
+}
+
+// parseLogicalNumber - parse a decimal or 0x prefixed hex number, a leading
+// 0 does not make it octal
+func parseLogicalNumber(s string) (int64, error) {
+	if hex, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		return strconv.ParseInt(hex, 16, 64)
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// MarshalText - encode the address by name
+func (a LogicalAddress) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText - decode an address in any form accepted by
+// ParseLogicalAddress
+func (a *LogicalAddress) UnmarshalText(text []byte) error {
+	address, err := ParseLogicalAddress(string(text))
+	if err != nil {
+		return err
+	}
+	*a = address
+	return nil
+}
+
+func (t DeviceType) String() string {
+	if name, ok := deviceTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("DeviceType(%d)", int(t))
+}
@@ -0,0 +1,77 @@
+package cec
+
+import "testing"
+
+func TestParseLogicalAddress(t *testing.T) {
+	tests := []struct {
+		in   string
+		want LogicalAddress
+		ok   bool
+	}{
+		{"TV", TV, true},
+		{"tv", TV, true},
+		{"Recording", Recording1, true},
+		{"Recording 1", Recording1, true},
+		{"recording-2", Recording2, true},
+		{"Playback_3", Playback3, true},
+		{"Audio", AudioSystem, true},
+		{"audio system", AudioSystem, true},
+		{"Tuner4", Tuner4, true},
+		{"Broadcast", Broadcast, true},
+		{"unregistered", Unregistered, true},
+		{"5", AudioSystem, true},
+		{"0xB", Playback3, true},
+		{"16", Unregistered, false},
+		{"", Unregistered, false},
+		{"Tuner11", Unregistered, false},
+		{"1", Recording1, true},
+		{"-1", Unregistered, false},
+		{" - 1", Unregistered, false},
+		{"0x10", Unregistered, false},
+		{"010", Tuner4, true},
+		{"08", Playback2, true},
+		{"300", Unregistered, false},
+		{"TV1", TV, true},
+		{"Audio1", AudioSystem, true},
+		{"Free1", Specific, true},
+		{"Broadcast1", Broadcast, true},
+		{"Playback21", Unregistered, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLogicalAddress(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseLogicalAddress(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestLogicalAddressString(t *testing.T) {
+	for a := TV; a <= Broadcast; a++ {
+		got, err := ParseLogicalAddress(a.String())
+		if err != nil || got != a {
+			t.Errorf("ParseLogicalAddress(%q) = %v, %v, want %v", a.String(), got, err, a)
+		}
+	}
+	if s := LogicalAddress(16).String(); s != "LogicalAddress(16)" {
+		t.Errorf("String() = %q for out of range address", s)
+	}
+	if GetLogicalNameByAddress(-1) != "LogicalAddress(-1)" {
+		t.Errorf("GetLogicalNameByAddress should not panic on invalid addresses")
+	}
+}
+
+func TestLogicalAddressDeviceType(t *testing.T) {
+	for a, want := range map[LogicalAddress]DeviceType{
+		TV:          DeviceTypeTV,
+		Recording3:  DeviceTypeRecording,
+		Tuner2:      DeviceTypeTuner,
+		Playback2:   DeviceTypePlayback,
+		AudioSystem: DeviceTypeAudioSystem,
+		Broadcast:   DeviceTypeUnknown,
+	} {
+		if got := a.DeviceType(); got != want {
+			t.Errorf("%v.DeviceType() = %v, want %v", a, got, want)
+		}
+	}
+}
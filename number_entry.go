@@ -0,0 +1,239 @@
+package cec
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NumberEnteredEvent - a number entered with the digit keys of a remote,
+// Text holds the digits and an optional dot, e.g. "42" or "5.1"
+type NumberEnteredEvent struct {
+	Initiator LogicalAddress
+	Text      string
+	Time      time.Time
+}
+
+func (ev NumberEnteredEvent) keyEvent() (KeyInfo, bool) {
+	return KeyInfo{Initiator: ev.Initiator, Time: ev.Time}, false
+}
+
+// Int - the entered number without the part after the dot
+func (e NumberEnteredEvent) Int() (int, error) {
+	whole, _, _ := strings.Cut(e.Text, ".")
+	return strconv.Atoi(whole)
+}
+
+// Float - the entered number
+func (e NumberEnteredEvent) Float() (float64, error) {
+	return strconv.ParseFloat(e.Text, 64)
+}
+
+// ChannelID - the entered number as a one-part channel number, or a
+// two-part channel number when it contains a dot
+func (e NumberEnteredEvent) ChannelID() (ChannelID, error) {
+	major, minor, ok := strings.Cut(e.Text, ".")
+	if !ok {
+		major, minor = "0", major
+	}
+
+	var channel ChannelID
+	var err error
+	if channel.Major, err = strconv.Atoi(major); err != nil {
+		return ChannelID{}, fmt.Errorf("Invalid channel %q", e.Text)
+	}
+	if channel.Minor, err = strconv.Atoi(minor); err != nil {
+		return ChannelID{}, fmt.Errorf("Invalid channel %q", e.Text)
+	}
+	return channel, nil
+}
+
+// NumberEntryOptions - how NumberEntry collects digits
+type NumberEntryOptions struct {
+	// Timeout is the time after the last digit at which the number is
+	// entered without Enter
+	Timeout time.Duration
+	// MaxDigits enters the number as soon as it has that many digits, 0
+	// means no limit
+	MaxDigits int
+	// Swallow drops the key events of keys used for the number
+	Swallow bool
+}
+
+// DefaultNumberEntryOptions - used for fields left 0 in NumberEntryOptions
+var DefaultNumberEntryOptions = NumberEntryOptions{
+	Timeout: 2 * time.Second,
+}
+
+type numberEntry struct {
+	opts    NumberEntryOptions
+	remotes map[LogicalAddress]*numberState
+}
+
+type numberState struct {
+	text   strings.Builder
+	digits int
+	last   time.Time
+	// consumed is set while the key used for the number is pressed
+	consumed   bool
+	consumeKey KeyCode
+}
+
+func newNumberEntry(opts NumberEntryOptions) *numberEntry {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultNumberEntryOptions.Timeout
+	}
+	return &numberEntry{opts: opts, remotes: make(map[LogicalAddress]*numberState)}
+}
+
+// NumberEntry - pass the events from in on, adding a NumberEnteredEvent
+// when digits (and an optional dot) are followed by Enter or the timeout.
+// Clear starts over and any other key cancels the number. The returned
+// channel is closed when in is closed or ctx is done
+func NumberEntry(ctx context.Context, in <-chan KeyEvent, opts NumberEntryOptions) <-chan KeyEvent {
+	n := newNumberEntry(opts)
+	out := make(chan KeyEvent, 16)
+
+	go func() {
+		defer close(out)
+
+		emit := func(events []KeyEvent) bool {
+			for _, ev := range events {
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			return true
+		}
+
+		for {
+			var wake <-chan time.Time
+			if next, ok := n.next(); ok {
+				wake = time.After(time.Until(next))
+			}
+
+			var events []KeyEvent
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-in:
+				if !ok {
+					return
+				}
+				events = n.feed(ev)
+			case now := <-wake:
+				events = n.expire(now)
+			}
+			if !emit(events) {
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// feed - handle an event, returns the events to pass on
+func (n *numberEntry) feed(ev KeyEvent) []KeyEvent {
+	info, ok := ev.keyEvent()
+	if !ok || info.Vendor {
+		return []KeyEvent{ev}
+	}
+
+	s := n.remotes[info.Initiator]
+	if s == nil {
+		s = new(numberState)
+		n.remotes[info.Initiator] = s
+	}
+
+	if _, ok := ev.(KeyDownEvent); !ok {
+		if s.consumed && info.Key == s.consumeKey {
+			if _, ok := ev.(KeyUpEvent); ok {
+				s.consumed = false
+			}
+			if n.opts.Swallow {
+				return nil
+			}
+		}
+		return []KeyEvent{ev}
+	}
+
+	s.consumed = false
+	var events []KeyEvent
+	if s.text.Len() > 0 && info.Time.Sub(s.last) >= n.opts.Timeout {
+		events = append(events, s.commit(info.Initiator, s.last.Add(n.opts.Timeout)))
+	}
+
+	consumed := false
+	switch {
+	case info.Key >= Key0 && info.Key <= Key9:
+		s.text.WriteByte(byte('0' + info.Key - Key0))
+		s.digits++
+		consumed = true
+	case info.Key == KeyDot && s.text.Len() > 0 && !strings.Contains(s.text.String(), "."):
+		s.text.WriteByte('.')
+		consumed = true
+	case info.Key == KeyClear && s.text.Len() > 0:
+		s.reset()
+		consumed = true
+	case info.Key == KeyEnter && s.text.Len() > 0:
+		consumed = true
+	default:
+		// any other key cancels the number
+		s.reset()
+	}
+	s.last = info.Time
+
+	if consumed {
+		s.consumed, s.consumeKey = true, info.Key
+	}
+	if !consumed || !n.opts.Swallow {
+		events = append(events, ev)
+	}
+
+	if info.Key == KeyEnter && consumed || n.opts.MaxDigits > 0 && s.digits >= n.opts.MaxDigits {
+		events = append(events, s.commit(info.Initiator, info.Time))
+	}
+	return events
+}
+
+// expire - enter numbers whose timeout has passed
+func (n *numberEntry) expire(now time.Time) []KeyEvent {
+	var events []KeyEvent
+	for address := TV; address <= Broadcast; address++ {
+		s := n.remotes[address]
+		if s != nil && s.text.Len() > 0 && now.Sub(s.last) >= n.opts.Timeout {
+			events = append(events, s.commit(address, s.last.Add(n.opts.Timeout)))
+		}
+	}
+	return events
+}
+
+// next - the time at which expire has to be called next
+func (n *numberEntry) next() (time.Time, bool) {
+	var next time.Time
+	for _, s := range n.remotes {
+		if s.text.Len() == 0 {
+			continue
+		}
+		if t := s.last.Add(n.opts.Timeout); next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	return next, !next.IsZero()
+}
+
+func (s *numberState) commit(initiator LogicalAddress, t time.Time) KeyEvent {
+	text := strings.TrimSuffix(s.text.String(), ".")
+	s.reset()
+	return NumberEnteredEvent{Initiator: initiator, Text: text, Time: t}
+}
+
+func (s *numberState) reset() {
+	s.text.Reset()
+	s.digits = 0
+}
@@ -0,0 +1,66 @@
+package cec
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNumberEntry(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	tests := []struct {
+		name   string
+		opts   NumberEntryOptions
+		keys   []KeyCode
+		expire int
+		want   []string
+	}{
+		{"enter", NumberEntryOptions{Swallow: true}, []KeyCode{Key4, Key2, KeyEnter}, 0, []string{"number 42"}},
+		{"dot", NumberEntryOptions{Swallow: true}, []KeyCode{Key5, KeyDot, Key1, KeyEnter}, 0, []string{"number 5.1"}},
+		{"timeout", NumberEntryOptions{Swallow: true}, []KeyCode{Key7}, 3000, []string{"number 7"}},
+		{"clear", NumberEntryOptions{Swallow: true}, []KeyCode{Key1, KeyClear, Key2, KeyEnter}, 0, []string{"number 2"}},
+		{"max digits", NumberEntryOptions{Swallow: true, MaxDigits: 2}, []KeyCode{Key1, Key2, Key3}, 0, []string{"number 12"}},
+		{"cancel", NumberEntryOptions{Swallow: true}, []KeyCode{Key1, KeyUp, KeyEnter}, 3000,
+			[]string{"down Up", "up Up 50ms false", "down Enter", "up Enter 50ms false"}},
+		{"pass through", NumberEntryOptions{}, []KeyCode{Key4, KeyEnter}, 0,
+			[]string{"down 4", "up 4 50ms false", "down Enter", "number 4", "up Enter 50ms false"}},
+	}
+
+	for _, tt := range tests {
+		n := newNumberEntry(tt.opts)
+		var out []KeyEvent
+		for i, key := range tt.keys {
+			info := KeyInfo{Initiator: TV, Key: key, Time: at(i * 100)}
+			out = append(out, n.feed(KeyDownEvent{KeyInfo: info})...)
+			info.Time = at(i*100 + 50)
+			out = append(out, n.feed(KeyUpEvent{KeyInfo: info, Held: 50 * time.Millisecond})...)
+		}
+		if tt.expire > 0 {
+			out = append(out, n.expire(at(tt.expire))...)
+		}
+
+		if got := describe(out); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: events = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNumberEnteredEvent(t *testing.T) {
+	ev := NumberEnteredEvent{Text: "5.1"}
+	if got, err := ev.Int(); err != nil || got != 5 {
+		t.Errorf("Int() = %d, %v", got, err)
+	}
+	if got, err := ev.Float(); err != nil || got != 5.1 {
+		t.Errorf("Float() = %v, %v", got, err)
+	}
+	if got, err := ev.ChannelID(); err != nil || got != (ChannelID{Major: 5, Minor: 1}) {
+		t.Errorf("ChannelID() = %v, %v", got, err)
+	}
+
+	ev.Text = "42"
+	if got, err := ev.ChannelID(); err != nil || got != (ChannelID{Minor: 42}) {
+		t.Errorf("ChannelID() = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,182 @@
+package cec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// number of times OneTouchPlay wakes the TV before giving up
+const oneTouchPlayAttempts = 3
+
+var (
+	// time a TV gets to report that it is on after each attempt
+	oneTouchPlayTimeout = 10 * time.Second
+	// pause between IMAGE_VIEW_ON and ACTIVE_SOURCE, some TVs drop
+	// messages while they start up
+	oneTouchPlayDelay = 200 * time.Millisecond
+	// time devices get to report that they are in standby
+	standbyConfirmTimeout = 5 * time.Second
+)
+
+// PowerChange - a device whose power status changed
+type PowerChange struct {
+	LogicalAddress LogicalAddress
+	Old            PowerStatus
+	New            PowerStatus
+}
+
+// OneTouchPlay - wake the TV and make our adapter the active source. The
+// TV is sent IMAGE_VIEW_ON (TEXT_VIEW_ON on retries), followed by
+// ACTIVE_SOURCE with our physical address, until it reports to be on. We
+// become the active source once ACTIVE_SOURCE is acknowledged or the TV
+// routes to us. Returns the devices that changed power status
+func (c *Connection) OneTouchPlay(ctx context.Context) ([]PowerChange, error) {
+	own, err := c.getOwnAddress(ctx)
+	if err != nil {
+		return nil, err
+	}
+	physical, err := c.GetDevicePhysicalAddressContext(ctx, own)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := c.queryPowerStatus(ctx, TV)
+	if isFatal(ctx, err) {
+		return nil, err
+	}
+
+	on := false
+	for attempt := 0; attempt < oneTouchPlayAttempts && !on; attempt++ {
+		opcode := opcodeImageViewOn
+		if attempt > 0 {
+			opcode = opcodeTextViewOn
+		}
+		if err := c.transmit(ctx, TV, opcode); isFatal(ctx, err) {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(oneTouchPlayDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		err := c.transmit(ctx, Broadcast, opcodeActiveSource, byte(physical>>8), byte(physical))
+		if isFatal(ctx, err) {
+			return nil, err
+		}
+		if err == nil {
+			// otherwise trackActiveSource records us once the TV reports
+			// routing to our address
+			c.announceActiveSource(own, physical)
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, oneTouchPlayTimeout)
+		err = c.WaitForPowerStatus(attemptCtx, TV, PowerStatusOn)
+		cancel()
+		if err == nil {
+			on = true
+		} else if isFatal(ctx, err) {
+			return nil, err
+		}
+	}
+
+	var changes []PowerChange
+	if on && before != PowerStatusOn {
+		changes = append(changes, PowerChange{LogicalAddress: TV, Old: before, New: PowerStatusOn})
+	}
+	if !on {
+		return changes, fmt.Errorf("%w: TV did not turn on", ErrTimeout)
+	}
+	return changes, nil
+}
+
+// SystemStandby - put all devices in standby except the ones listed. With
+// no exceptions STANDBY is broadcast, otherwise it is sent to every other
+// active device. Returns the devices that reported to change power status
+func (c *Connection) SystemStandby(ctx context.Context, except ...LogicalAddress) ([]PowerChange, error) {
+	active, err := c.GetActiveDevicesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	own, err := c.getOwnAddresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	spared := make(map[LogicalAddress]bool)
+	for _, address := range except {
+		spared[address] = true
+	}
+
+	var targets []LogicalAddress
+	for i := range active {
+		address := LogicalAddress(i)
+		if active[i] && !own[i] && !spared[address] && address != Broadcast {
+			targets = append(targets, address)
+		}
+	}
+
+	before := make(map[LogicalAddress]PowerStatus)
+	for _, address := range targets {
+		status, err := c.GetDevicePowerStatusContext(ctx, address)
+		if isFatal(ctx, err) {
+			return nil, err
+		}
+		before[address] = status
+	}
+
+	var errs []error
+	if len(except) == 0 {
+		if err := c.transmit(ctx, Broadcast, opcodeStandby); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, address := range targets {
+			if err := c.transmit(ctx, address, opcodeStandby); err != nil {
+				if isFatal(ctx, err) {
+					return nil, err
+				}
+				errs = append(errs, fmt.Errorf("%s: %w", address, err))
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var changes []PowerChange
+	waitCtx, cancel := context.WithTimeout(ctx, standbyConfirmTimeout)
+	defer cancel()
+
+	for _, address := range targets {
+		c.state.invalidate(address, fieldPowerStatus)
+		if before[address] == PowerStatusStandby {
+			continue
+		}
+
+		wg.Add(1)
+		go func(address LogicalAddress) {
+			defer wg.Done()
+
+			if err := c.WaitForPowerStatus(waitCtx, address, PowerStatusStandby); err != nil {
+				return
+			}
+			mu.Lock()
+			changes = append(changes, PowerChange{LogicalAddress: address, Old: before[address], New: PowerStatusStandby})
+			mu.Unlock()
+		}(address)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return changes, err
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].LogicalAddress < changes[j].LogicalAddress
+	})
+	return changes, errors.Join(errs...)
+}
@@ -0,0 +1,172 @@
+package cec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newOneTouchBus(t *testing.T) *fakeBus {
+	timeout, delay := oneTouchPlayTimeout, oneTouchPlayDelay
+	oneTouchPlayTimeout, oneTouchPlayDelay = 50*time.Millisecond, time.Millisecond
+	t.Cleanup(func() { oneTouchPlayTimeout, oneTouchPlayDelay = timeout, delay })
+
+	bus := newFakeBus(Playback1)
+	bus.c.updateState(command(Playback1, Broadcast, opcodeReportPhysicalAddress, 0x10, 0x00, 0x04))
+	return bus
+}
+
+func TestOneTouchPlayRetry(t *testing.T) {
+	bus := newOneTouchBus(t)
+	// the TV only wakes up for TEXT_VIEW_ON
+	bus.onTransmit(func(msg *Command) {
+		if msg.Opcode == opcodeTextViewOn {
+			bus.setPower(TV, PowerStatusOn)
+		}
+	})
+
+	changes, err := bus.c.OneTouchPlay(context.Background())
+	if err != nil {
+		t.Fatalf("OneTouchPlay() = %v", err)
+	}
+	want := []string{"IMAGE_VIEW_ON", "ACTIVE_SOURCE", "TEXT_VIEW_ON", "ACTIVE_SOURCE"}
+	if sent := bus.opcodes(); !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent %v, want %v", sent, want)
+	}
+	if len(changes) != 1 || changes[0] != (PowerChange{LogicalAddress: TV, Old: PowerStatusStandby, New: PowerStatusOn}) {
+		t.Errorf("changes = %+v", changes)
+	}
+	if current, ok := bus.c.ActiveSource(); !ok || current.LogicalAddress != Playback1 || current.PhysicalAddress != 0x1000 {
+		t.Errorf("ActiveSource() = %+v", current)
+	}
+}
+
+func TestOneTouchPlayUnacknowledged(t *testing.T) {
+	bus := newOneTouchBus(t)
+	bus.setPower(TV, PowerStatusOn)
+	bus.nack[opcodeActiveSource] = true
+
+	if _, err := bus.c.OneTouchPlay(context.Background()); err != nil {
+		t.Fatalf("OneTouchPlay() = %v", err)
+	}
+	if current, ok := bus.c.ActiveSource(); ok {
+		t.Fatalf("ActiveSource() = %+v before any confirmation", current)
+	}
+
+	bus.receive(command(TV, Broadcast, opcodeRoutingChange, 0x00, 0x00, 0x10, 0x00))
+	if current, ok := bus.c.ActiveSource(); !ok || current.LogicalAddress != Playback1 {
+		t.Errorf("ActiveSource() = %+v after the TV routed to us", current)
+	}
+}
+
+func TestOneTouchPlayClose(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		bus := newOneTouchBus(t)
+		bus.setPower(TV, PowerStatusOn)
+		bus.c.ActiveSourceChanges = make(chan *ActiveSourceInfo, 1)
+
+		// hold the state cache from the ACTIVE_SOURCE acknowledgement until
+		// Close has run, so the active source is recorded after Close
+		closed := make(chan error, 1)
+		bus.onTransmit(func(msg *Command) {
+			if msg.Opcode != opcodeActiveSource {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			closed <- bus.c.Close(ctx)
+			bus.c.state.mu.Unlock()
+		})
+		bus.c.hooks.transmit = func(transmit func(*Command) bool) func(*Command) bool {
+			return func(msg *Command) bool {
+				if msg.Opcode == opcodeActiveSource {
+					bus.c.state.mu.Lock()
+				}
+				return transmit(msg)
+			}
+		}(bus.c.hooks.transmit)
+
+		played := make(chan struct{})
+		go func() {
+			bus.c.OneTouchPlay(context.Background())
+			close(played)
+		}()
+		<-closed
+		<-played
+		if err := bus.c.Close(context.Background()); err != nil {
+			t.Fatalf("Close() = %v", err)
+		}
+	}
+}
+
+// newStandbyBus - a bus on which the given devices go to standby when
+// told to, before the STANDBY is acknowledged
+func newStandbyBus(t *testing.T, comply ...LogicalAddress) *fakeBus {
+	timeout := standbyConfirmTimeout
+	standbyConfirmTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { standbyConfirmTimeout = timeout })
+
+	bus := newFakeBus(Recording1)
+	for _, address := range []LogicalAddress{TV, Recording1, Playback1, AudioSystem} {
+		bus.setPresent(address, true)
+		bus.setPower(address, PowerStatusOn)
+	}
+	transmit := bus.c.hooks.transmit
+	bus.c.hooks.transmit = func(msg *Command) bool {
+		if msg.Opcode == opcodeStandby {
+			for _, address := range comply {
+				if msg.Destination == Broadcast || msg.Destination == address {
+					bus.setPower(address, PowerStatusStandby)
+				}
+			}
+		}
+		return transmit(msg)
+	}
+	return bus
+}
+
+func TestSystemStandbyBroadcast(t *testing.T) {
+	bus := newStandbyBus(t, TV, AudioSystem)
+	bus.setPower(AudioSystem, PowerStatusStandby)
+
+	changes, err := bus.c.SystemStandby(context.Background())
+	if err != nil {
+		t.Fatalf("SystemStandby() = %v", err)
+	}
+	if len(bus.sent) != 1 || bus.sent[0].Opcode != opcodeStandby || bus.sent[0].Destination != Broadcast {
+		t.Errorf("sent %+v, want a single broadcast STANDBY", bus.sent)
+	}
+	// Playback1 ignored the STANDBY, AudioSystem was in standby already
+	want := []PowerChange{{LogicalAddress: TV, Old: PowerStatusOn, New: PowerStatusStandby}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+}
+
+func TestSystemStandbyExcept(t *testing.T) {
+	bus := newStandbyBus(t, TV, Playback1)
+
+	changes, err := bus.c.SystemStandby(context.Background(), Playback1)
+	if err != nil {
+		t.Fatalf("SystemStandby() = %v", err)
+	}
+
+	var sentTo []LogicalAddress
+	for _, msg := range bus.sent {
+		if msg.Opcode == opcodeStandby {
+			sentTo = append(sentTo, msg.Destination)
+		}
+	}
+	// our own address and the spared Playback1 are skipped
+	if want := []LogicalAddress{TV, AudioSystem}; !reflect.DeepEqual(sentTo, want) {
+		t.Errorf("STANDBY sent to %v, want %v", sentTo, want)
+	}
+	if status, _ := bus.c.queryPowerStatus(context.Background(), Playback1); status != PowerStatusOn {
+		t.Errorf("spared Playback1 is %v", status)
+	}
+	want := []PowerChange{{LogicalAddress: TV, Old: PowerStatusOn, New: PowerStatusStandby}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+}
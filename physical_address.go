@@ -0,0 +1,190 @@
+package cec
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PhysicalAddress - HDMI physical address a.b.c.d of a device. Each nibble
+// is the input port used on the device one level up the HDMI tree, the TV
+// is 0.0.0.0 and the first 0 ends the path
+type PhysicalAddress uint16
+
+// InvalidPhysicalAddress - reported by devices that don't know their
+// physical address
+const InvalidPhysicalAddress PhysicalAddress = 0xFFFF
+
+// ParsePhysicalAddress - parse a physical address written as "1.2.0.0",
+// "1:2:0:0" or "1200". With separators every part is a single hex digit.
+// Addresses that fail IsValid, such as "1.0.2.0" or "f.f.f.f", are
+// rejected
+func ParsePhysicalAddress(s string) (PhysicalAddress, error) {
+	digits := strings.TrimSpace(s)
+	if strings.ContainsAny(digits, ".:") {
+		parts := strings.Split(strings.ReplaceAll(digits, ":", "."), ".")
+		if len(parts) != 4 {
+			return InvalidPhysicalAddress, fmt.Errorf("Invalid physical address %q", s)
+		}
+		for _, part := range parts {
+			if len(part) != 1 {
+				return InvalidPhysicalAddress, fmt.Errorf("Invalid physical address %q", s)
+			}
+		}
+		digits = strings.Join(parts, "")
+	}
+	if len(digits) != 4 {
+		return InvalidPhysicalAddress, fmt.Errorf("Invalid physical address %q", s)
+	}
+
+	value, err := strconv.ParseUint(digits, 16, 16)
+	if err != nil || !PhysicalAddress(value).IsValid() {
+		return InvalidPhysicalAddress, fmt.Errorf("Invalid physical address %q", s)
+	}
+
+	return PhysicalAddress(value), nil
+}
+
+func (p PhysicalAddress) String() string {
+	return fmt.Sprintf("%x.%x.%x.%x", p.nibble(0), p.nibble(1), p.nibble(2), p.nibble(3))
+}
+
+// Uint16 - the address as sent on the bus
+func (p PhysicalAddress) Uint16() uint16 {
+	return uint16(p)
+}
+
+// nibble - the port at the given level, 0 is the port on the TV
+func (p PhysicalAddress) nibble(level int) int {
+	return int(p>>(12-4*level)) & 0xF
+}
+
+// IsValid - check that the address is not InvalidPhysicalAddress and has
+// no port after the end of the path
+func (p PhysicalAddress) IsValid() bool {
+	if p == InvalidPhysicalAddress {
+		return false
+	}
+
+	end := false
+	for level := 0; level < 4; level++ {
+		if p.nibble(level) == 0 {
+			end = true
+		} else if end {
+			return false
+		}
+	}
+	return true
+}
+
+// Depth - number of HDMI hops from the TV, 0 for the TV itself
+func (p PhysicalAddress) Depth() int {
+	depth := 0
+	for depth < 4 && p.nibble(depth) != 0 {
+		depth++
+	}
+	return depth
+}
+
+// Port - the input port on the parent device the device is connected to,
+// 0 for the TV
+func (p PhysicalAddress) Port() int {
+	depth := p.Depth()
+	if depth == 0 {
+		return 0
+	}
+	return p.nibble(depth - 1)
+}
+
+// Parent - the address of the device one level up, the TV has no parent
+// and returns InvalidPhysicalAddress
+func (p PhysicalAddress) Parent() PhysicalAddress {
+	depth := p.Depth()
+	if depth == 0 || !p.IsValid() {
+		return InvalidPhysicalAddress
+	}
+	return p &^ (0xF << (16 - 4*depth))
+}
+
+// Child - the address of the device connected to the given input port
+func (p PhysicalAddress) Child(port int) (PhysicalAddress, error) {
+	if !p.IsValid() {
+		return InvalidPhysicalAddress, fmt.Errorf("Invalid physical address %s", p)
+	}
+	if port < 1 || port > 15 {
+		return InvalidPhysicalAddress, fmt.Errorf("Invalid port %d", port)
+	}
+	depth := p.Depth()
+	if depth == 4 {
+		return InvalidPhysicalAddress, fmt.Errorf("%s is at the maximum depth", p)
+	}
+	return p | PhysicalAddress(port)<<(12-4*depth), nil
+}
+
+// IsAncestorOf - check if q is connected, directly or through other
+// devices, to an input of p
+func (p PhysicalAddress) IsAncestorOf(q PhysicalAddress) bool {
+	if !p.IsValid() || !q.IsValid() {
+		return false
+	}
+
+	depth := p.Depth()
+	if depth >= q.Depth() {
+		return false
+	}
+	for level := 0; level < depth; level++ {
+		if p.nibble(level) != q.nibble(level) {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalText - encode the address as "a.b.c.d"
+func (p PhysicalAddress) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText - decode an address written in any form accepted by
+// ParsePhysicalAddress or InvalidPhysicalAddress as encoded by MarshalText
+func (p *PhysicalAddress) UnmarshalText(text []byte) error {
+	if string(text) == InvalidPhysicalAddress.String() {
+		*p = InvalidPhysicalAddress
+		return nil
+	}
+	addr, err := ParsePhysicalAddress(string(text))
+	if err != nil {
+		return err
+	}
+	*p = addr
+	return nil
+}
+
+// SetStreamPath - ask the TV and switches to route the given physical
+// address to the screen
+func (c *Connection) SetStreamPath(addr PhysicalAddress) error {
+	return c.SetStreamPathContext(context.Background(), addr)
+}
+
+// SetStreamPathContext - like SetStreamPath, but gives up when ctx is done
+func (c *Connection) SetStreamPathContext(ctx context.Context, addr PhysicalAddress) error {
+	if !addr.IsValid() {
+		return fmt.Errorf("Invalid physical address %s", addr)
+	}
+	return c.transmit(ctx, Broadcast, opcodeSetStreamPath, byte(addr>>8), byte(addr))
+}
+
+// RoutingChange - announce that a switch changed its input from one
+// physical address to another
+func (c *Connection) RoutingChange(from PhysicalAddress, to PhysicalAddress) error {
+	return c.RoutingChangeContext(context.Background(), from, to)
+}
+
+// RoutingChangeContext - like RoutingChange, but gives up when ctx is done
+func (c *Connection) RoutingChangeContext(ctx context.Context, from PhysicalAddress, to PhysicalAddress) error {
+	if !from.IsValid() || !to.IsValid() {
+		return fmt.Errorf("Invalid routing change from %s to %s", from, to)
+	}
+	return c.transmit(ctx, Broadcast, opcodeRoutingChange, byte(from>>8), byte(from), byte(to>>8), byte(to))
+}
@@ -0,0 +1,92 @@
+package cec
+
+import "testing"
+
+func TestParsePhysicalAddress(t *testing.T) {
+	tests := []struct {
+		in   string
+		want PhysicalAddress
+		ok   bool
+	}{
+		{"0.0.0.0", 0x0000, true},
+		{"1.2.0.0", 0x1200, true},
+		{"a.b.c.d", 0xABCD, true},
+		{"3:1:0:0", 0x3100, true},
+		{"1000", 0x1000, true},
+		{"1.0.0", InvalidPhysicalAddress, false},
+		{"1.20.0", InvalidPhysicalAddress, false},
+		{"12.0.0", InvalidPhysicalAddress, false},
+		{"1.2.00", InvalidPhysicalAddress, false},
+		{"1..2.0", InvalidPhysicalAddress, false},
+		{"1.2.0.0.", InvalidPhysicalAddress, false},
+		{"+100", InvalidPhysicalAddress, false},
+		{"x.0.0.0", InvalidPhysicalAddress, false},
+		{"", InvalidPhysicalAddress, false},
+		{"f.f.f.f", InvalidPhysicalAddress, false},
+		{"FFFF", InvalidPhysicalAddress, false},
+		{"1.0.2.0", InvalidPhysicalAddress, false},
+		{"0100", InvalidPhysicalAddress, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePhysicalAddress(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParsePhysicalAddress(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+
+	// unknown addresses survive encoding
+	var addr PhysicalAddress
+	text, _ := InvalidPhysicalAddress.MarshalText()
+	if err := addr.UnmarshalText(text); err != nil || addr != InvalidPhysicalAddress {
+		t.Errorf("UnmarshalText(%q) = %v, %v", text, addr, err)
+	}
+	if err := addr.UnmarshalText([]byte("1.0.2.0")); err == nil {
+		t.Errorf("UnmarshalText(1.0.2.0) = %v, want an error", addr)
+	}
+}
+
+func TestPhysicalAddressTree(t *testing.T) {
+	avr := PhysicalAddress(0x2000)
+	console, err := avr.Child(1)
+	if err != nil || console != 0x2100 {
+		t.Fatalf("Child(1) = %v, %v", console, err)
+	}
+
+	if console.String() != "2.1.0.0" {
+		t.Errorf("String() = %q", console.String())
+	}
+	if console.Depth() != 2 || avr.Depth() != 1 || PhysicalAddress(0).Depth() != 0 {
+		t.Errorf("unexpected depth")
+	}
+	if console.Port() != 1 || avr.Port() != 2 {
+		t.Errorf("unexpected port")
+	}
+	if console.Parent() != avr || avr.Parent() != 0 || PhysicalAddress(0).Parent() != InvalidPhysicalAddress {
+		t.Errorf("unexpected parent")
+	}
+	if !avr.IsAncestorOf(console) || !PhysicalAddress(0).IsAncestorOf(console) {
+		t.Errorf("expected %v to be an ancestor of %v", avr, console)
+	}
+	if console.IsAncestorOf(avr) || avr.IsAncestorOf(avr) || PhysicalAddress(0x1000).IsAncestorOf(console) {
+		t.Errorf("unexpected ancestor")
+	}
+	if _, err := PhysicalAddress(0x1234).Child(1); err == nil {
+		t.Errorf("expected error for child beyond maximum depth")
+	}
+}
+
+func TestPhysicalAddressIsValid(t *testing.T) {
+	for addr, want := range map[PhysicalAddress]bool{
+		0x0000:                 true,
+		0x1000:                 true,
+		0x1234:                 true,
+		0x1020:                 false,
+		0x0100:                 false,
+		InvalidPhysicalAddress: false,
+	} {
+		if addr.IsValid() != want {
+			t.Errorf("%v.IsValid() = %v, want %v", addr, !want, want)
+		}
+	}
+}
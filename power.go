@@ -0,0 +1,118 @@
+package cec
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PowerStatus - power status of a device
+type PowerStatus int
+
+const (
+	PowerStatusOn           PowerStatus = 0x00
+	PowerStatusStandby      PowerStatus = 0x01
+	PowerStatusStarting     PowerStatus = 0x02 // in transition standby to on
+	PowerStatusShuttingDown PowerStatus = 0x03 // in transition on to standby
+	PowerStatusUnknown      PowerStatus = 0x99
+)
+
+// interval between power status requests while waiting for a change
+const powerStatusPollInterval = time.Second
+
+func (s PowerStatus) String() string {
+	switch s {
+	case PowerStatusOn:
+		return "on"
+	case PowerStatusStandby:
+		return "standby"
+	case PowerStatusStarting:
+		return "starting"
+	case PowerStatusShuttingDown:
+		return "shutting down"
+	case PowerStatusUnknown:
+		return "unknown"
+	default:
+		return fmt.Sprintf("PowerStatus(0x%02X)", int(s))
+	}
+}
+
+// WaitForPowerStatus - wait until the device at the given address reports
+// the given power status. The device is asked for its status every second
+// and REPORT_POWER_STATUS messages it sends by itself are picked up
+// immediately. Devices often don't answer while they change state, failed
+// queries are retried until ctx is done
+func (c *Connection) WaitForPowerStatus(ctx context.Context, address LogicalAddress, status PowerStatus) error {
+	matched := make(chan struct{}, 1)
+	remove := c.listen(func(msg *Command) {
+		params := msg.Parameters.Bytes()
+		if msg.Opcode != opcodeReportPowerStatus || msg.Initiator != address || len(params) < 1 {
+			return
+		}
+		if PowerStatus(params[0]) == status {
+			select {
+			case matched <- struct{}{}:
+			default:
+			}
+		}
+	})
+	defer remove()
+
+	ticker := time.NewTicker(powerStatusPollInterval)
+	defer ticker.Stop()
+
+	current, err := c.queryPowerStatus(ctx, address)
+	for {
+		if err == nil && current == status {
+			return nil
+		}
+		if isFatal(ctx, err) {
+			return err
+		}
+
+		select {
+		case <-matched:
+			return nil
+		case <-ticker.C:
+			// libcec sends GIVE_DEVICE_POWER_STATUS for the query
+			current, err = c.queryPowerStatus(ctx, address)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// PowerOnAndWait - power on the device with the given logical address and
+// wait until it reports to be on
+func (c *Connection) PowerOnAndWait(ctx context.Context, address LogicalAddress) error {
+	if err := c.PowerOnContext(ctx, address); err != nil {
+		return err
+	}
+	return c.WaitForPowerStatus(ctx, address, PowerStatusOn)
+}
+
+// StandbyAndWait - put the device with the given address in standby mode
+// and wait until it reports to be in standby
+func (c *Connection) StandbyAndWait(ctx context.Context, address LogicalAddress) error {
+	if err := c.StandbyContext(ctx, address); err != nil {
+		return err
+	}
+	return c.WaitForPowerStatus(ctx, address, PowerStatusStandby)
+}
+
+// MarshalText - encode the status by name
+func (s PowerStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText - decode a status encoded by MarshalText
+func (s *PowerStatus) UnmarshalText(text []byte) error {
+	for _, status := range []PowerStatus{PowerStatusOn, PowerStatusStandby,
+		PowerStatusStarting, PowerStatusShuttingDown, PowerStatusUnknown} {
+		if status.String() == string(text) {
+			*s = status
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid power status %q", text)
+}
@@ -0,0 +1,91 @@
+package cec
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitForPowerStatusReport(t *testing.T) {
+	bus := newFakeBus(Playback1)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		bus.receive(command(TV, Broadcast, opcodeReportPowerStatus, byte(PowerStatusOn)))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := bus.c.WaitForPowerStatus(ctx, TV, PowerStatusOn); err != nil {
+		t.Fatalf("WaitForPowerStatus() = %v", err)
+	}
+
+	// the latest of several reports counts
+	bus.setPower(TV, PowerStatusStandby)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		bus.receive(command(TV, Broadcast, opcodeReportPowerStatus, byte(PowerStatusStarting)))
+		bus.receive(command(TV, Broadcast, opcodeReportPowerStatus, byte(PowerStatusOn)))
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := bus.c.WaitForPowerStatus(ctx, TV, PowerStatusOn); err != nil {
+		t.Fatalf("WaitForPowerStatus() after two reports = %v", err)
+	}
+
+	// reports from other devices don't count
+	bus.setPower(TV, PowerStatusOn)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		bus.receive(command(Playback2, Broadcast, opcodeReportPowerStatus, byte(PowerStatusStandby)))
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := bus.c.WaitForPowerStatus(ctx, TV, PowerStatusStandby); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitForPowerStatus() = %v, want the deadline", err)
+	}
+}
+
+func TestWaitForPowerStatusPoll(t *testing.T) {
+	bus := newFakeBus(Playback1)
+	bus.setPower(TV, PowerStatusStarting)
+
+	go func() {
+		time.Sleep(powerStatusPollInterval / 2)
+		bus.setPower(TV, PowerStatusOn)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*powerStatusPollInterval)
+	defer cancel()
+	if err := bus.c.WaitForPowerStatus(ctx, TV, PowerStatusOn); err != nil {
+		t.Fatalf("WaitForPowerStatus() = %v", err)
+	}
+	// libcec asks the device itself, nothing else goes on the bus
+	if sent := bus.opcodes(); len(sent) != 0 {
+		t.Errorf("transmitted %v while polling", sent)
+	}
+	if dev, ok := bus.c.state.fresh(TV, fieldPowerStatus); !ok || dev.PowerStatus != PowerStatusOn {
+		t.Errorf("cached power status %v, want on", dev.PowerStatus)
+	}
+}
+
+func TestWaitForPowerStatusClosed(t *testing.T) {
+	bus := newFakeBus(Playback1)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- bus.c.WaitForPowerStatus(context.Background(), TV, PowerStatusOn)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	bus.c.Close(context.Background())
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("WaitForPowerStatus() = %v, want ErrClosed", err)
+		}
+	case <-time.After(3 * powerStatusPollInterval):
+		t.Fatal("WaitForPowerStatus() kept waiting after Close")
+	}
+}
@@ -0,0 +1,292 @@
+package cec
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how long the devices looked up by Resolve are reused, traffic that
+// announces a new name, vendor or address refreshes them earlier
+const resolveTTL = 30 * time.Second
+
+// maximum number of aliases followed when resolving a name
+const maxAliasDepth = 8
+
+var portPattern = regexp.MustCompile(`^(?:hdmi|port|input)([0-9]{1,2})$`)
+
+var deviceTypeWords = map[string]DeviceType{
+	"tv": DeviceTypeTV, "television": DeviceTypeTV,
+	"recording": DeviceTypeRecording, "recorder": DeviceTypeRecording,
+	"recordingdevice": DeviceTypeRecording, "tuner": DeviceTypeTuner,
+	"playback": DeviceTypePlayback, "player": DeviceTypePlayback,
+	"playbackdevice": DeviceTypePlayback, "audio": DeviceTypeAudioSystem,
+	"audiosystem": DeviceTypeAudioSystem, "amp": DeviceTypeAudioSystem,
+}
+
+type resolverEntry struct {
+	address  LogicalAddress
+	osdName  string
+	vendor   string
+	physical PhysicalAddress
+}
+
+type resolver struct {
+	mu        sync.Mutex
+	aliases   map[string]string
+	entries   []resolverEntry
+	refreshed time.Time
+	listening bool
+}
+
+// normalizeName - lower case name without separators and a leading "the"
+func normalizeName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimPrefix(name, "the ")
+	return removeSeparators(name)
+}
+
+// AddAlias - make alias resolve to whatever target resolves to, e.g.
+// AddAlias("sonos", "audio system") or AddAlias("playstation", "HDMI 3")
+func (c *Connection) AddAlias(alias string, target string) {
+	c.resolver.mu.Lock()
+	defer c.resolver.mu.Unlock()
+
+	if c.resolver.aliases == nil {
+		c.resolver.aliases = make(map[string]string)
+	}
+	c.resolver.aliases[normalizeName(alias)] = target
+}
+
+// RemoveAlias - remove an alias added with AddAlias
+func (c *Connection) RemoveAlias(alias string) {
+	c.resolver.mu.Lock()
+	defer c.resolver.mu.Unlock()
+
+	delete(c.resolver.aliases, normalizeName(alias))
+}
+
+// Resolve - get the current logical address of a device given by alias,
+// OSD name, HDMI port on the TV ("HDMI 3"), device type ("audio system"),
+// logical address name or number, physical address ("2.1.0.0") or vendor
+// name, tried in that order so an OSD name like "1234" is not taken for a
+// physical address. The ByName methods and SwitchTo accept the same names
+func (c *Connection) Resolve(ctx context.Context, target string) (LogicalAddress, error) {
+	return c.resolve(ctx, target, 0)
+}
+
+// PowerOnByName - like PowerOnContext for the device with the given name,
+// see Resolve
+func (c *Connection) PowerOnByName(ctx context.Context, name string) error {
+	address, err := c.Resolve(ctx, name)
+	if err != nil {
+		return err
+	}
+	return c.PowerOnContext(ctx, address)
+}
+
+// StandbyByName - like StandbyContext for the device with the given name,
+// see Resolve
+func (c *Connection) StandbyByName(ctx context.Context, name string) error {
+	address, err := c.Resolve(ctx, name)
+	if err != nil {
+		return err
+	}
+	return c.StandbyContext(ctx, address)
+}
+
+// KeyByName - like KeyContext for the device with the given name, see
+// Resolve
+func (c *Connection) KeyByName(ctx context.Context, name string, key interface{}) error {
+	address, err := c.Resolve(ctx, name)
+	if err != nil {
+		return err
+	}
+	return c.KeyContext(ctx, address, key)
+}
+
+// HoldKeyByName - like HoldKey for the device with the given name, see
+// Resolve
+func (c *Connection) HoldKeyByName(ctx context.Context, name string, key interface{}, duration time.Duration) error {
+	address, err := c.Resolve(ctx, name)
+	if err != nil {
+		return err
+	}
+	return c.HoldKey(ctx, address, key, duration)
+}
+
+// KeySequenceByName - like KeySequenceContext for the device with the
+// given name, see Resolve
+func (c *Connection) KeySequenceByName(ctx context.Context, name string, keys []KeyCode, gap time.Duration) error {
+	address, err := c.Resolve(ctx, name)
+	if err != nil {
+		return err
+	}
+	return c.KeySequenceContext(ctx, address, keys, gap)
+}
+
+// SetOSDStringByName - like SetOSDStringContext for the device with the
+// given name, see Resolve
+func (c *Connection) SetOSDStringByName(ctx context.Context, name string, str string) error {
+	address, err := c.Resolve(ctx, name)
+	if err != nil {
+		return err
+	}
+	return c.SetOSDStringContext(ctx, address, str)
+}
+
+func (c *Connection) resolve(ctx context.Context, target string, depth int) (LogicalAddress, error) {
+	key := normalizeName(target)
+	if key == "" {
+		return Unregistered, fmt.Errorf("%w: empty device name", ErrNotPresent)
+	}
+
+	c.resolver.mu.Lock()
+	alias, isAlias := c.resolver.aliases[key]
+	c.resolver.mu.Unlock()
+	if isAlias {
+		if depth >= maxAliasDepth {
+			return Unregistered, fmt.Errorf("Alias loop resolving %q", target)
+		}
+		return c.resolve(ctx, alias, depth+1)
+	}
+
+	entries, err := c.resolverEntries(ctx)
+	if err != nil {
+		return Unregistered, err
+	}
+
+	for _, entry := range entries {
+		if entry.osdName != "" && normalizeName(entry.osdName) == key {
+			return entry.address, nil
+		}
+	}
+
+	if m := portPattern.FindStringSubmatch(key); m != nil {
+		port, _ := strconv.Atoi(m[1])
+		return resolvePort(entries, port)
+	}
+
+	if deviceType, ok := deviceTypeWords[key]; ok {
+		for _, entry := range entries {
+			if entry.address.DeviceType() == deviceType {
+				return entry.address, nil
+			}
+		}
+	}
+
+	if address, err := ParseLogicalAddress(target); err == nil {
+		return address, nil
+	}
+
+	if physical, err := ParsePhysicalAddress(target); err == nil {
+		for _, entry := range entries {
+			if entry.physical == physical {
+				return entry.address, nil
+			}
+		}
+		return Unregistered, fmt.Errorf("%w: no device at %s", ErrNotPresent, physical)
+	}
+
+	for _, entry := range entries {
+		if entry.vendor != "" && normalizeName(entry.vendor) == key {
+			return entry.address, nil
+		}
+	}
+
+	return Unregistered, fmt.Errorf("%w: no device matches %q", ErrNotPresent, target)
+}
+
+// resolvePort - find the device closest to the given input port of the TV
+func resolvePort(entries []resolverEntry, port int) (LogicalAddress, error) {
+	input, err := PhysicalAddress(0).Child(port)
+	if err != nil {
+		return Unregistered, err
+	}
+
+	if address, ok := closestBelow(entries, input); ok {
+		return address, nil
+	}
+	return Unregistered, fmt.Errorf("%w: no device on HDMI %d", ErrNotPresent, port)
+}
+
+// closestBelow - the device at the given physical address or the one
+// closest below it
+func closestBelow(entries []resolverEntry, physical PhysicalAddress) (LogicalAddress, bool) {
+	best := -1
+	for i, entry := range entries {
+		if entry.physical != physical && !physical.IsAncestorOf(entry.physical) {
+			continue
+		}
+		if best < 0 || entry.physical.Depth() < entries[best].physical.Depth() {
+			best = i
+		}
+	}
+	if best < 0 {
+		return Unregistered, false
+	}
+	return entries[best].address, true
+}
+
+// resolverEntries - the present devices, refreshed when older than
+// resolveTTL or invalidated by bus traffic
+func (c *Connection) resolverEntries(ctx context.Context) ([]resolverEntry, error) {
+	c.resolver.mu.Lock()
+	if !c.resolver.listening {
+		c.resolver.listening = true
+		c.listen(c.invalidateResolver)
+	}
+	if c.resolver.entries != nil && time.Since(c.resolver.refreshed) < resolveTTL {
+		entries := c.resolver.entries
+		c.resolver.mu.Unlock()
+		return entries, nil
+	}
+	c.resolver.mu.Unlock()
+
+	active, err := c.GetActiveDevicesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []resolverEntry{}
+	for i, present := range active {
+		if !present {
+			continue
+		}
+		entry := resolverEntry{address: LogicalAddress(i)}
+		if entry.physical, err = c.GetDevicePhysicalAddressContext(ctx, entry.address); isFatal(ctx, err) {
+			return nil, err
+		}
+		if entry.osdName, err = c.GetDeviceOSDNameContext(ctx, entry.address); isFatal(ctx, err) {
+			return nil, err
+		}
+		vendorID, err := c.GetDeviceVendorIDContext(ctx, entry.address)
+		if isFatal(ctx, err) {
+			return nil, err
+		}
+		entry.vendor = GetVendorByID(vendorID)
+		entries = append(entries, entry)
+	}
+
+	c.resolver.mu.Lock()
+	c.resolver.entries = entries
+	c.resolver.refreshed = time.Now()
+	c.resolver.mu.Unlock()
+
+	return entries, nil
+}
+
+// invalidateResolver - forget the looked up devices when a device
+// announces a new name, vendor or address
+func (c *Connection) invalidateResolver(msg *Command) {
+	switch msg.Opcode {
+	case opcodeReportPhysicalAddress, opcodeSetOSDName, opcodeDeviceVendorID:
+		c.resolver.mu.Lock()
+		c.resolver.entries = nil
+		c.resolver.mu.Unlock()
+	}
+}
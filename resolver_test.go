@@ -0,0 +1,125 @@
+package cec
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResolvePort(t *testing.T) {
+	entries := []resolverEntry{
+		{address: TV, physical: 0x0000},
+		{address: Playback2, physical: 0x2100},
+		{address: AudioSystem, physical: 0x2000},
+		{address: Playback1, physical: 0x3000},
+	}
+
+	for port, want := range map[int]LogicalAddress{2: AudioSystem, 3: Playback1} {
+		got, err := resolvePort(entries, port)
+		if err != nil || got != want {
+			t.Errorf("resolvePort(%d) = %v, %v, want %v", port, got, err, want)
+		}
+	}
+
+	if _, err := resolvePort(entries, 1); !errors.Is(err, ErrNotPresent) {
+		t.Errorf("resolvePort(1) = %v, want ErrNotPresent", err)
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	for in, want := range map[string]string{
+		"The Sonos":    "sonos",
+		"HDMI 3":       "hdmi3",
+		"audio-system": "audiosystem",
+		" PlayStation": "playstation",
+	} {
+		if got := normalizeName(in); got != want {
+			t.Errorf("normalizeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	c := new(Connection)
+	c.resolver.listening = true
+	c.resolver.refreshed = time.Now()
+	c.resolver.entries = []resolverEntry{
+		{address: TV, osdName: "TV", vendor: "Samsung", physical: 0x0000},
+		{address: AudioSystem, osdName: "Sonos Arc", vendor: "Sonos", physical: 0x2000},
+		{address: Playback1, osdName: "1234", vendor: "Sony", physical: 0x1000},
+		{address: Playback2, osdName: "BEEF", physical: 0x2100},
+		{address: Tuner1, osdName: "Receiver", physical: 0x1234},
+	}
+	c.AddAlias("soundbar", "the sonos arc")
+	c.AddAlias("loop", "loop")
+
+	tests := []struct {
+		in   string
+		want LogicalAddress
+		ok   bool
+	}{
+		{"Sonos Arc", AudioSystem, true},
+		{"soundbar", AudioSystem, true},
+		{"1234", Playback1, true},
+		{"BEEF", Playback2, true},
+		{"1.2.3.4", Tuner1, true},
+		{"2.1.0.0", Playback2, true},
+		{"HDMI 2", AudioSystem, true},
+		{"audio system", AudioSystem, true},
+		{"Playback 2", Playback2, true},
+		{"11", Playback3, true},
+		{"sony", Playback1, true},
+		{"3.0.0.0", Unregistered, false},
+		{"HDMI 4", Unregistered, false},
+		{"Xbox", Unregistered, false},
+		{"loop", Unregistered, false},
+		{"", Unregistered, false},
+	}
+
+	for _, tt := range tests {
+		got, err := c.Resolve(context.Background(), tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("Resolve(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestByName(t *testing.T) {
+	bus := newFakeBus(Recording1)
+	bus.c.resolver.listening = true
+	bus.c.resolver.refreshed = time.Now()
+	bus.c.resolver.entries = []resolverEntry{
+		{address: TV, osdName: "TV", physical: 0x0000},
+		{address: AudioSystem, osdName: "Sonos Arc", physical: 0x2000},
+		{address: Playback1, osdName: "Player", physical: 0x1000},
+	}
+	ctx := context.Background()
+
+	for name, want := range map[string]LogicalAddress{"Sonos Arc": AudioSystem, "HDMI 1": Playback1} {
+		if err := bus.c.PowerOnByName(ctx, name); err != nil {
+			t.Errorf("PowerOnByName(%q) = %v", name, err)
+		}
+		if status, _ := bus.c.queryPowerStatus(ctx, want); status != PowerStatusOn {
+			t.Errorf("PowerOnByName(%q) left %v %v", name, want, status)
+		}
+	}
+
+	for name, want := range map[string]LogicalAddress{"player": Playback1, "HDMI 2": AudioSystem} {
+		bus.sent = nil
+		if err := bus.c.KeyByName(ctx, name, KeySelect); err != nil {
+			t.Errorf("KeyByName(%q) = %v", name, err)
+		}
+		if len(bus.sent) != 2 || bus.sent[0].Destination != want || bus.sent[0].Parameters.Bytes()[0] != byte(KeySelect) {
+			t.Errorf("KeyByName(%q) sent %+v, want Select to %v", name, bus.sent, want)
+		}
+	}
+
+	bus.sent = nil
+	if err := bus.c.KeyByName(ctx, "HDMI 3", KeySelect); !errors.Is(err, ErrNotPresent) {
+		t.Errorf("KeyByName(HDMI 3) = %v, want ErrNotPresent", err)
+	}
+	if len(bus.sent) != 0 {
+		t.Errorf("sent %v for an unknown device", bus.opcodes())
+	}
+}
@@ -0,0 +1,139 @@
+package cec
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// how long SwitchTo waits for the new source to announce itself
+var switchConfirmTimeout = 5 * time.Second
+
+// SwitchTo - make the TV show the given target, which is a
+// PhysicalAddress, the number of an HDMI input on the TV (int), a
+// LogicalAddress, a Device or a name understood by Resolve. The TV is
+// woken first if needed, then SET_STREAM_PATH is sent and ROUTING_CHANGE
+// as a fallback for TVs ignoring it. The switch is confirmed by
+// ACTIVE_SOURCE from a device at or behind the target, or by
+// ROUTING_INFORMATION or ROUTING_CHANGE reporting the target, only then
+// it is recorded as the active source. Returns ErrNotSwitched if neither
+// arrives, which is also the case for inputs without a CEC device
+func (c *Connection) SwitchTo(ctx context.Context, target interface{}) error {
+	physical, err := c.targetPhysicalAddress(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	status, err := c.GetDevicePowerStatusContext(ctx, TV)
+	if isFatal(ctx, err) {
+		return err
+	}
+	if status != PowerStatusOn {
+		if err := c.PowerOnAndWait(ctx, TV); err != nil {
+			return fmt.Errorf("Error waking TV: %w", err)
+		}
+	}
+
+	previous, ok := c.ActiveSource()
+	if ok && switchedTo(previous, physical) {
+		return nil
+	}
+	from := previous.PhysicalAddress
+	if !ok {
+		from = 0
+	}
+
+	if err := c.SetStreamPathContext(ctx, physical); err != nil {
+		return err
+	}
+	if c.waitForActiveSource(ctx, physical) {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	slog.Debug("CEC stream path ignored, sending routing change", "from", from, "to", physical)
+	if err := c.RoutingChangeContext(ctx, from, physical); err != nil {
+		return err
+	}
+	if c.waitForActiveSource(ctx, physical) {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: %s", ErrNotSwitched, physical)
+}
+
+// targetPhysicalAddress - the physical address of a SwitchTo target
+func (c *Connection) targetPhysicalAddress(ctx context.Context, target interface{}) (PhysicalAddress, error) {
+	switch target := target.(type) {
+	case PhysicalAddress:
+		if !target.IsValid() {
+			return InvalidPhysicalAddress, fmt.Errorf("Invalid physical address %s", target)
+		}
+		return target, nil
+	case int:
+		return PhysicalAddress(0).Child(target)
+	case LogicalAddress:
+		return c.GetDevicePhysicalAddressContext(ctx, target)
+	case Device:
+		if target.PhysicalAddress.IsValid() {
+			return target.PhysicalAddress, nil
+		}
+		return c.GetDevicePhysicalAddressContext(ctx, target.LogicalAddress)
+	case *Device:
+		return c.targetPhysicalAddress(ctx, *target)
+	case string:
+		address, err := c.Resolve(ctx, target)
+		if err == nil {
+			return c.GetDevicePhysicalAddressContext(ctx, address)
+		}
+		if isFatal(ctx, err) {
+			return InvalidPhysicalAddress, err
+		}
+		// an input without a device behind it
+		if physical, perr := ParsePhysicalAddress(target); perr == nil {
+			return c.targetPhysicalAddress(ctx, physical)
+		}
+		return InvalidPhysicalAddress, err
+	default:
+		return InvalidPhysicalAddress, fmt.Errorf("Invalid switch target type %T", target)
+	}
+}
+
+// switchedTo - check if the active source is at or behind physical
+func switchedTo(current ActiveSourceInfo, physical PhysicalAddress) bool {
+	return current.PhysicalAddress == physical || physical.IsAncestorOf(current.PhysicalAddress)
+}
+
+// waitForActiveSource - wait up to switchConfirmTimeout for a device at or
+// behind physical to become the active source, as recorded by
+// trackActiveSource from received messages
+func (c *Connection) waitForActiveSource(ctx context.Context, physical PhysicalAddress) bool {
+	ctx, cancel := context.WithTimeout(ctx, switchConfirmTimeout)
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	remove := c.listen(func(*Command) {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	defer remove()
+
+	for {
+		if current, ok := c.ActiveSource(); ok && switchedTo(current, physical) {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
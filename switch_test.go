@@ -0,0 +1,94 @@
+package cec
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newSwitchBus(t *testing.T) *fakeBus {
+	timeout := switchConfirmTimeout
+	switchConfirmTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { switchConfirmTimeout = timeout })
+
+	bus := newFakeBus(Recording1)
+	bus.c.updateState(command(TV, Recording1, opcodeReportPowerStatus, byte(PowerStatusOn)))
+	bus.c.updateState(command(Playback1, Broadcast, opcodeReportPhysicalAddress, 0x10, 0x00, 0x04))
+	return bus
+}
+
+func TestSwitchToKnownDevice(t *testing.T) {
+	bus := newSwitchBus(t)
+	bus.onTransmit(func(msg *Command) {
+		if msg.Opcode == opcodeSetStreamPath {
+			bus.receive(command(Playback1, Broadcast, opcodeActiveSource, 0x10, 0x00))
+		}
+	})
+
+	if err := bus.c.SwitchTo(context.Background(), PhysicalAddress(0x1000)); err != nil {
+		t.Fatalf("SwitchTo() = %v", err)
+	}
+	if current, ok := bus.c.ActiveSource(); !ok || current.LogicalAddress != Playback1 {
+		t.Errorf("ActiveSource() = %+v", current)
+	}
+	if sent := bus.opcodes(); !reflect.DeepEqual(sent, []string{"SET_STREAM_PATH"}) {
+		t.Errorf("sent %v", sent)
+	}
+}
+
+func TestSwitchToUnknownInput(t *testing.T) {
+	bus := newSwitchBus(t)
+	// a switch on HDMI 3 reports the route to its active input
+	bus.onTransmit(func(msg *Command) {
+		if msg.Opcode == opcodeSetStreamPath {
+			bus.receive(command(Unregistered, Broadcast, opcodeRoutingInformation, 0x31, 0x00))
+		}
+	})
+
+	if err := bus.c.SwitchTo(context.Background(), 3); err != nil {
+		t.Fatalf("SwitchTo() = %v", err)
+	}
+	if current, ok := bus.c.ActiveSource(); !ok || current.PhysicalAddress != 0x3100 {
+		t.Errorf("ActiveSource() = %+v", current)
+	}
+}
+
+func TestSwitchToRoutingChangeFallback(t *testing.T) {
+	bus := newSwitchBus(t)
+	bus.c.trackActiveSource(command(Playback1, Broadcast, opcodeActiveSource, 0x10, 0x00))
+	bus.onTransmit(func(msg *Command) {
+		if msg.Opcode == opcodeRoutingChange {
+			bus.receive(command(Playback2, Broadcast, opcodeActiveSource, 0x20, 0x00))
+		}
+	})
+
+	if err := bus.c.SwitchTo(context.Background(), "2.0.0.0"); err != nil {
+		t.Fatalf("SwitchTo() = %v", err)
+	}
+	if sent := bus.opcodes(); !reflect.DeepEqual(sent, []string{"SET_STREAM_PATH", "ROUTING_CHANGE"}) {
+		t.Errorf("sent %v", sent)
+	}
+	if params := bus.sent[1].Parameters.Bytes(); !reflect.DeepEqual(params, []byte{0x10, 0x00, 0x20, 0x00}) {
+		t.Errorf("ROUTING_CHANGE parameters % x", params)
+	}
+	if current, _ := bus.c.ActiveSource(); current.LogicalAddress != Playback2 {
+		t.Errorf("ActiveSource() = %+v", current)
+	}
+}
+
+func TestSwitchToUnconfirmed(t *testing.T) {
+	bus := newSwitchBus(t)
+
+	err := bus.c.SwitchTo(context.Background(), PhysicalAddress(0x4000))
+	if !errors.Is(err, ErrNotSwitched) {
+		t.Fatalf("SwitchTo() = %v, want ErrNotSwitched", err)
+	}
+	if current, ok := bus.c.ActiveSource(); ok {
+		t.Errorf("ActiveSource() = %+v, an unconfirmed switch must not be recorded", current)
+	}
+	if sent := bus.opcodes(); !reflect.DeepEqual(sent, []string{"SET_STREAM_PATH", "ROUTING_CHANGE"}) {
+		t.Errorf("sent %v", sent)
+	}
+}
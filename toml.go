@@ -0,0 +1,223 @@
+package cec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tomlTable - the settings of one [[binding]] table, values are string,
+// bool or []interface{}
+type tomlTable map[string]interface{}
+
+// parseTOML - parse the subset of TOML used by keymaps and return its
+// [[binding]] tables. Each line is blank, a comment, a [[binding]] header
+// or a key = value pair with a bare key. Values are basic ("...") or
+// literal ('...') strings, booleans or arrays of those on a single line
+func parseTOML(data string) ([]tomlTable, error) {
+	var tables []tomlTable
+	for i, line := range strings.Split(data, "\n") {
+		p := &tomlParser{line: strings.TrimRight(line, "\r"), number: i + 1}
+		p.skipBlank()
+		if p.eof() {
+			continue
+		}
+
+		if p.peek() == '[' {
+			if err := p.header(); err != nil {
+				return nil, err
+			}
+			tables = append(tables, make(tomlTable))
+			continue
+		}
+
+		key, value, err := p.pair()
+		if err != nil {
+			return nil, err
+		}
+		if len(tables) == 0 {
+			return nil, p.errorf("%s set outside a [[binding]]", key)
+		}
+		table := tables[len(tables)-1]
+		if _, ok := table[key]; ok {
+			return nil, p.errorf("Duplicate key %s", key)
+		}
+		table[key] = value
+	}
+	return tables, nil
+}
+
+// tomlParser - parser for a single line
+type tomlParser struct {
+	line   string
+	pos    int
+	number int
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Line %d: %s", p.number, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.line)
+}
+
+func (p *tomlParser) peek() byte {
+	return p.line[p.pos]
+}
+
+// skipBlank - skip spaces and a comment
+func (p *tomlParser) skipBlank() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+	if !p.eof() && p.peek() == '#' {
+		p.pos = len(p.line)
+	}
+}
+
+// end - check that nothing but a comment follows
+func (p *tomlParser) end() error {
+	p.skipBlank()
+	if !p.eof() {
+		return p.errorf("Unexpected %q", p.line[p.pos:])
+	}
+	return nil
+}
+
+// header - parse a [[binding]] header, the only table keymaps have
+func (p *tomlParser) header() error {
+	rest := p.line[p.pos:]
+	if i := strings.Index(rest, "]]"); strings.HasPrefix(rest, "[[") && i > 0 {
+		if name := strings.TrimSpace(rest[2:i]); name != "binding" {
+			return p.errorf("Unknown table [[%s]]", name)
+		}
+		p.pos += i + 2
+		return p.end()
+	}
+	return p.errorf("Unknown table %s", strings.TrimSpace(rest))
+}
+
+// pair - parse a key = value line
+func (p *tomlParser) pair() (string, interface{}, error) {
+	start := p.pos
+	for !p.eof() {
+		c := p.peek()
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	key := p.line[start:p.pos]
+	if key == "" {
+		return "", nil, p.errorf("Expected key, got %q", p.line[p.pos:])
+	}
+
+	p.skipBlank()
+	if p.eof() || p.peek() != '=' {
+		return "", nil, p.errorf("Expected = after %s", key)
+	}
+	p.pos++
+	p.skipBlank()
+
+	value, err := p.value(true)
+	if err != nil {
+		return "", nil, err
+	}
+	return key, value, p.end()
+}
+
+// value - parse a string, a boolean or, if allowed, an array
+func (p *tomlParser) value(array bool) (interface{}, error) {
+	if p.eof() {
+		return nil, p.errorf("Expected value")
+	}
+
+	rest := p.line[p.pos:]
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		return p.string()
+	case c == '[' && array:
+		return p.array()
+	case strings.HasPrefix(rest, "true"):
+		p.pos += 4
+		return true, nil
+	case strings.HasPrefix(rest, "false"):
+		p.pos += 5
+		return false, nil
+	default:
+		return nil, p.errorf("Unsupported value %s", rest)
+	}
+}
+
+// string - parse a basic string with \" \\ \n \t escapes or a literal
+// string without escapes
+func (p *tomlParser) string() (string, error) {
+	quote := p.peek()
+	p.pos++
+
+	var b strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		p.pos++
+		if c == quote {
+			return b.String(), nil
+		}
+		if c != '\\' || quote == '\'' {
+			b.WriteByte(c)
+			continue
+		}
+
+		if p.eof() {
+			break
+		}
+		c = p.peek()
+		p.pos++
+		switch c {
+		case '"', '\\':
+			b.WriteByte(c)
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		default:
+			return "", p.errorf("Unsupported escape \\%c", c)
+		}
+	}
+	return "", p.errorf("Unterminated string")
+}
+
+// array - parse [value, ...], a trailing comma is allowed
+func (p *tomlParser) array() ([]interface{}, error) {
+	p.pos++
+
+	values := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil, p.errorf("Unterminated array")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return values, nil
+		}
+
+		value, err := p.value(false)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		p.skipBlank()
+		if p.eof() {
+			return nil, p.errorf("Unterminated array")
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("Expected , or ] in array")
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package cec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TopologyNode - a physical address in the HDMI tree. Devices sharing a
+// physical address are merged into one node, switches without CEC that
+// can only be inferred from the addresses behind them are marked Inferred
+type TopologyNode struct {
+	PhysicalAddress  PhysicalAddress  `json:"physicalAddress"`
+	LogicalAddresses []LogicalAddress `json:"logicalAddresses,omitempty"`
+	Name             string           `json:"name,omitempty"`
+	Vendor           string           `json:"vendor,omitempty"`
+	PowerStatus      PowerStatus      `json:"powerStatus"`
+	Inferred         bool             `json:"inferred,omitempty"`
+	Children         []*TopologyNode  `json:"children,omitempty"`
+}
+
+// Topology - reconstruct the HDMI tree from the physical addresses of all
+// active devices
+func (c *Connection) Topology(ctx context.Context) (*TopologyNode, error) {
+	devices, err := c.DevicesContext(ctx)
+	return BuildTopology(devices), err
+}
+
+// BuildTopology - arrange devices in a tree by physical address, rooted at
+// the TV. Devices without a valid physical address are left out
+func BuildTopology(devices []Device) *TopologyNode {
+	nodes := make(map[PhysicalAddress]*TopologyNode)
+
+	var node func(addr PhysicalAddress) *TopologyNode
+	node = func(addr PhysicalAddress) *TopologyNode {
+		if n, ok := nodes[addr]; ok {
+			return n
+		}
+		n := &TopologyNode{PhysicalAddress: addr, PowerStatus: PowerStatusUnknown, Inferred: true}
+		nodes[addr] = n
+		if addr.Depth() > 0 {
+			parent := node(addr.Parent())
+			parent.Children = append(parent.Children, n)
+		}
+		return n
+	}
+
+	root := node(0)
+	for _, dev := range devices {
+		if !dev.PhysicalAddress.IsValid() {
+			continue
+		}
+		n := node(dev.PhysicalAddress)
+		n.LogicalAddresses = append(n.LogicalAddresses, dev.LogicalAddress)
+		if n.Inferred {
+			n.Inferred = false
+			n.Name = dev.OSDName
+			n.Vendor = dev.Vendor
+			n.PowerStatus = dev.PowerStatus
+		}
+	}
+
+	for _, n := range nodes {
+		sort.Slice(n.Children, func(i, j int) bool {
+			return n.Children[i].PhysicalAddress < n.Children[j].PhysicalAddress
+		})
+		sort.Slice(n.LogicalAddresses, func(i, j int) bool {
+			return n.LogicalAddresses[i] < n.LogicalAddresses[j]
+		})
+	}
+	return root
+}
+
+// label - one line description of the node
+func (n *TopologyNode) label() string {
+	parts := []string{n.PhysicalAddress.String()}
+	if n.Inferred {
+		parts = append(parts, "(switch without CEC)")
+	}
+	if n.Name != "" {
+		parts = append(parts, n.Name)
+	}
+	if len(n.LogicalAddresses) > 0 {
+		names := make([]string, len(n.LogicalAddresses))
+		for i, address := range n.LogicalAddresses {
+			names[i] = address.String()
+		}
+		parts = append(parts, "["+strings.Join(names, ", ")+"]")
+	}
+	if n.Vendor != "" {
+		parts = append(parts, n.Vendor)
+	}
+	if !n.Inferred && n.PowerStatus != PowerStatusUnknown {
+		parts = append(parts, n.PowerStatus.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// Text - render the tree as indented text, one node per line
+func (n *TopologyNode) Text() string {
+	var sb strings.Builder
+	n.writeText(&sb, 0)
+	return sb.String()
+}
+
+func (n *TopologyNode) writeText(sb *strings.Builder, indent int) {
+	sb.WriteString(strings.Repeat("  ", indent))
+	if n.PhysicalAddress.Depth() > 0 {
+		fmt.Fprintf(sb, "HDMI %d: ", n.PhysicalAddress.Port())
+	}
+	sb.WriteString(n.label())
+	sb.WriteString("\n")
+	for _, child := range n.Children {
+		child.writeText(sb, indent+1)
+	}
+}
+
+// JSON - render the tree as indented JSON
+func (n *TopologyNode) JSON() ([]byte, error) {
+	return json.MarshalIndent(n, "", "  ")
+}
+
+// DOT - render the tree as a Graphviz digraph, edges are labelled with
+// the input port and inferred switches are drawn dashed
+func (n *TopologyNode) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph hdmi {\n")
+	sb.WriteString("  node [shape=box];\n")
+	n.writeDOT(&sb)
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func (n *TopologyNode) writeDOT(sb *strings.Builder) {
+	style := ""
+	if n.Inferred {
+		style = ", style=dashed"
+	}
+	fmt.Fprintf(sb, "  %q [label=%q%s];\n", n.PhysicalAddress.String(), n.label(), style)
+	for _, child := range n.Children {
+		fmt.Fprintf(sb, "  %q -> %q [label=%q];\n", n.PhysicalAddress.String(),
+			child.PhysicalAddress.String(), fmt.Sprintf("HDMI %d", child.PhysicalAddress.Port()))
+	}
+	for _, child := range n.Children {
+		child.writeDOT(sb)
+	}
+}
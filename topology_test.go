@@ -0,0 +1,69 @@
+package cec
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func testDevices() []Device {
+	return []Device{
+		{LogicalAddress: TV, OSDName: "TV", Vendor: "LG", PhysicalAddress: 0x0000, PowerStatus: PowerStatusOn},
+		{LogicalAddress: Recording1, OSDName: "cec.go", PhysicalAddress: 0x1000, PowerStatus: PowerStatusOn},
+		{LogicalAddress: Playback1, OSDName: "PS5", Vendor: "Sony", PhysicalAddress: 0x2100, PowerStatus: PowerStatusStandby},
+		{LogicalAddress: AudioSystem, OSDName: "AVR", Vendor: "Denon", PhysicalAddress: 0x2000, PowerStatus: PowerStatusOn},
+		{LogicalAddress: Playback2, OSDName: "Apple TV", PhysicalAddress: 0x3200, PowerStatus: PowerStatusUnknown},
+		{LogicalAddress: Tuner1, OSDName: "Lost", PhysicalAddress: InvalidPhysicalAddress},
+	}
+}
+
+func TestBuildTopology(t *testing.T) {
+	root := BuildTopology(testDevices())
+
+	if root.Inferred || root.Name != "TV" || len(root.Children) != 3 {
+		t.Fatalf("unexpected root %+v", root)
+	}
+
+	avr := root.Children[1]
+	if avr.PhysicalAddress != 0x2000 || len(avr.Children) != 1 || avr.Children[0].Name != "PS5" {
+		t.Errorf("unexpected AVR node %+v", avr)
+	}
+
+	hidden := root.Children[2]
+	if !hidden.Inferred || hidden.PhysicalAddress != 0x3000 || len(hidden.Children) != 1 {
+		t.Errorf("expected an inferred switch at 3.0.0.0, got %+v", hidden)
+	}
+}
+
+func TestTopologyRenderers(t *testing.T) {
+	root := BuildTopology(testDevices())
+
+	text := root.Text()
+	for _, want := range []string{
+		"0.0.0.0 TV [TV] LG on\n",
+		"  HDMI 2: 2.0.0.0 AVR [Audio] Denon on\n",
+		"    HDMI 1: 2.1.0.0 PS5 [Playback] Sony standby\n",
+		"  HDMI 3: 3.0.0.0 (switch without CEC)\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Text() is missing %q:\n%s", want, text)
+		}
+	}
+
+	dot := root.DOT()
+	if !strings.Contains(dot, `"2.0.0.0" -> "2.1.0.0" [label="HDMI 1"];`) || !strings.Contains(dot, "style=dashed") {
+		t.Errorf("unexpected DOT output:\n%s", dot)
+	}
+
+	data, err := root.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded TopologyNode
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() = %v\n%s", err, data)
+	}
+	if len(decoded.Children) != 3 || decoded.Children[1].LogicalAddresses[0] != AudioSystem {
+		t.Errorf("unexpected JSON round trip:\n%s", data)
+	}
+}
@@ -0,0 +1,129 @@
+//go:build linux
+
+package cec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// uinput ioctls, see linux/uinput.h
+const (
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+
+	uinputMaxNameSize = 80
+	absCount          = 64
+	busVirtual        = 0x06
+)
+
+// uinputUserDev - struct uinput_user_dev
+type uinputUserDev struct {
+	Name         [uinputMaxNameSize]byte
+	BusType      uint16
+	Vendor       uint16
+	Product      uint16
+	Version      uint16
+	FFEffectsMax uint32
+	AbsMax       [absCount]int32
+	AbsMin       [absCount]int32
+	AbsFuzz      [absCount]int32
+	AbsFlat      [absCount]int32
+}
+
+// inputEvent - struct input_event
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+type uinputDevice struct {
+	f *os.File
+}
+
+// OpenUInput - create a virtual input device with the given name that can
+// send the given Linux key codes
+func OpenUInput(name string, keys []uint16) (InputWriter, error) {
+	f, err := os.OpenFile("/dev/uinput", os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening uinput: %w", err)
+	}
+
+	if err := ioctl(f, uiSetEvBit, evKey); err != nil {
+		f.Close()
+		return nil, err
+	}
+	for _, key := range keys {
+		if err := ioctl(f, uiSetKeyBit, uintptr(key)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	dev := uinputUserDev{BusType: busVirtual, Vendor: 0x1582, Product: 0x0001, Version: 1}
+	copy(dev.Name[:uinputMaxNameSize-1], name)
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.NativeEndian, &dev); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("Error setting up uinput device: %w", err)
+	}
+	if err := ioctl(f, uiDevCreate, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &uinputDevice{f: f}, nil
+}
+
+// NewUInputSink - create an input sink writing to a new uinput device, a
+// nil keymap uses DefaultInputKeymap
+func NewUInputSink(name string, keymap map[KeyCode]uint16) (*InputSink, error) {
+	if keymap == nil {
+		keymap = DefaultInputKeymap
+	}
+	w, err := OpenUInput(name, inputKeys(keymap))
+	if err != nil {
+		return nil, err
+	}
+	return NewInputSink(w, keymap), nil
+}
+
+func (d *uinputDevice) WriteEvent(typ uint16, code uint16, value int32) error {
+	ev := inputEvent{
+		Time:  syscall.NsecToTimeval(time.Now().UnixNano()),
+		Type:  typ,
+		Code:  code,
+		Value: value,
+	}
+	_, err := d.f.Write(unsafe.Slice((*byte)(unsafe.Pointer(&ev)), unsafe.Sizeof(ev)))
+	return err
+}
+
+func (d *uinputDevice) Close() error {
+	err := ioctl(d.f, uiDevDestroy, 0)
+	if er := d.f.Close(); er != nil && err == nil {
+		err = er
+	}
+	return err
+}
+
+func ioctl(f *os.File, request uintptr, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, arg)
+	if errno != 0 {
+		return fmt.Errorf("Error in uinput ioctl %#x: %w", request, errno)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package cec
+
+import (
+	"context"
+	"time"
+)
+
+// DeviceEvent - DeviceAdded, DeviceRemoved or DeviceChanged sent by Watch
+type DeviceEvent interface {
+	deviceEvent()
+}
+
+// DeviceAdded - a device appeared on the bus
+type DeviceAdded struct {
+	Device Device
+}
+
+// DeviceRemoved - a device no longer answers polls, Device holds its last
+// known state
+type DeviceRemoved struct {
+	Device Device
+}
+
+// DeviceChanged - a property of a device changed, Field is the name of
+// the Device field
+type DeviceChanged struct {
+	LogicalAddress LogicalAddress
+	Field          string
+	Old            interface{}
+	New            interface{}
+}
+
+func (DeviceAdded) deviceEvent()   {}
+func (DeviceRemoved) deviceEvent() {}
+func (DeviceChanged) deviceEvent() {}
+
+// WatchOptions - how often Watch asks the bus for changes
+type WatchOptions struct {
+	// PollInterval is the time between polls of the known devices and
+	// power status checks
+	PollInterval time.Duration
+	// RescanInterval is the time between full bus rescans that find
+	// devices that keep quiet, 0 uses the default and a negative value
+	// disables rescans
+	RescanInterval time.Duration
+	// DeviceTimeout bounds the time spent querying a new device
+	DeviceTimeout time.Duration
+}
+
+// DefaultWatchOptions - used for fields left 0 in WatchOptions
+var DefaultWatchOptions = WatchOptions{
+	PollInterval:   10 * time.Second,
+	RescanInterval: time.Minute,
+	DeviceTimeout:  3 * time.Second,
+}
+
+type watcher struct {
+	c      *Connection
+	opts   WatchOptions
+	events chan DeviceEvent
+	known  map[LogicalAddress]Device
+}
+
+// Watch - report devices that are added, removed or change until ctx is
+// done or the connection is closed, then the returned channel is closed.
+// Changes are picked up from bus traffic as it arrives and by polling
+func (c *Connection) Watch(ctx context.Context, opts WatchOptions) <-chan DeviceEvent {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultWatchOptions.PollInterval
+	}
+	if opts.RescanInterval == 0 {
+		opts.RescanInterval = DefaultWatchOptions.RescanInterval
+	}
+	if opts.DeviceTimeout <= 0 {
+		opts.DeviceTimeout = DefaultWatchOptions.DeviceTimeout
+	}
+
+	w := &watcher{
+		c:      c,
+		opts:   opts,
+		events: make(chan DeviceEvent, 16),
+		known:  make(map[LogicalAddress]Device),
+	}
+
+	traffic := make(chan LogicalAddress, 16)
+	remove := c.listen(func(msg *Command) {
+		select {
+		case traffic <- msg.Initiator:
+		default:
+			// the next poll catches up
+		}
+	})
+
+	go func() {
+		defer close(w.events)
+		defer remove()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-c.done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		w.poll(ctx, opts.RescanInterval > 0)
+
+		poll := time.NewTicker(opts.PollInterval)
+		defer poll.Stop()
+
+		var rescan <-chan time.Time
+		if opts.RescanInterval > 0 {
+			ticker := time.NewTicker(opts.RescanInterval)
+			defer ticker.Stop()
+			rescan = ticker.C
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case address := <-traffic:
+				w.traffic(ctx, address)
+			case <-poll.C:
+				w.poll(ctx, false)
+			case <-rescan:
+				w.poll(ctx, true)
+			}
+		}
+	}()
+
+	return w.events
+}
+
+func (w *watcher) emit(ctx context.Context, event DeviceEvent) {
+	select {
+	case w.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+// add - query a new device and report it
+func (w *watcher) add(ctx context.Context, address LogicalAddress) {
+	deviceCtx, cancel := context.WithTimeout(ctx, w.opts.DeviceTimeout)
+	defer cancel()
+
+	dev := w.c.queryDevice(deviceCtx, address)
+	if ctx.Err() != nil {
+		return
+	}
+	w.known[address] = dev
+	w.emit(ctx, DeviceAdded{Device: dev})
+}
+
+// update - report the differences between the known and the given state
+func (w *watcher) update(ctx context.Context, dev Device) {
+	old := w.known[dev.LogicalAddress]
+	changes := diffDevices(old, dev)
+	for _, change := range changes {
+		w.emit(ctx, change)
+	}
+	if len(changes) > 0 {
+		w.known[dev.LogicalAddress] = mergeDevice(old, dev)
+	}
+}
+
+// traffic - a message from address arrived and the cache is updated
+func (w *watcher) traffic(ctx context.Context, address LogicalAddress) {
+	if !address.IsValid() || address == Unregistered {
+		return
+	}
+	if _, ok := w.known[address]; !ok {
+		w.add(ctx, address)
+		return
+	}
+	if dev, ok := w.c.state.device(address); ok {
+		w.update(ctx, dev)
+	}
+}
+
+// poll - find added and removed devices and check the power status of
+// the known ones
+func (w *watcher) poll(ctx context.Context, rescan bool) {
+	if rescan {
+		if err := w.c.RescanDevicesContext(ctx); err != nil {
+			return
+		}
+	}
+
+	active, err := w.c.GetActiveDevicesContext(ctx)
+	if err != nil {
+		return
+	}
+	own, err := w.c.getOwnAddresses(ctx)
+	if err != nil {
+		return
+	}
+
+	for i := range active {
+		address := LogicalAddress(i)
+		dev, known := w.known[address]
+
+		if active[i] && !known {
+			w.add(ctx, address)
+			continue
+		}
+		if !known || own[i] {
+			continue
+		}
+
+		present, err := w.c.PollDeviceContext(ctx, address)
+		if err != nil {
+			return
+		}
+		if !present {
+			delete(w.known, address)
+			w.emit(ctx, DeviceRemoved{Device: dev})
+			continue
+		}
+
+		if status, err := w.c.queryPowerStatus(ctx, address); err == nil {
+			current := dev
+			current.PowerStatus = status
+			w.update(ctx, current)
+		}
+	}
+}
+
+// diffDevices - the changed properties, properties that are unknown in
+// the new state are not reported as changed
+func diffDevices(old Device, new Device) []DeviceChanged {
+	var changes []DeviceChanged
+	add := func(field string, o interface{}, n interface{}) {
+		changes = append(changes, DeviceChanged{
+			LogicalAddress: new.LogicalAddress,
+			Field:          field,
+			Old:            o,
+			New:            n,
+		})
+	}
+
+	if new.PhysicalAddress != InvalidPhysicalAddress && new.PhysicalAddress != old.PhysicalAddress {
+		add(fieldPhysicalAddress, old.PhysicalAddress, new.PhysicalAddress)
+	}
+	if new.OSDName != "" && new.OSDName != old.OSDName {
+		add(fieldOSDName, old.OSDName, new.OSDName)
+	}
+	if new.VendorID != 0 && new.VendorID != old.VendorID {
+		add(fieldVendorID, old.VendorID, new.VendorID)
+	}
+	if new.CECVersion != CECVersionUnknown && new.CECVersion != old.CECVersion {
+		add(fieldCECVersion, old.CECVersion, new.CECVersion)
+	}
+	if new.MenuLanguage != "" && new.MenuLanguage != old.MenuLanguage {
+		add(fieldMenuLanguage, old.MenuLanguage, new.MenuLanguage)
+	}
+	if new.PowerStatus != PowerStatusUnknown && new.PowerStatus != old.PowerStatus {
+		add(fieldPowerStatus, old.PowerStatus, new.PowerStatus)
+	}
+	if new.ActiveSource != old.ActiveSource {
+		add(fieldActiveSource, old.ActiveSource, new.ActiveSource)
+	}
+	if new.DeviceType != DeviceTypeUnknown && new.DeviceType != old.DeviceType {
+		add(fieldDeviceType, old.DeviceType, new.DeviceType)
+	}
+
+	return changes
+}
+
+// mergeDevice - old with the known properties of new applied
+func mergeDevice(old Device, new Device) Device {
+	for _, change := range diffDevices(old, new) {
+		switch change.Field {
+		case fieldPhysicalAddress:
+			old.PhysicalAddress = new.PhysicalAddress
+		case fieldOSDName:
+			old.OSDName = new.OSDName
+		case fieldVendorID:
+			old.VendorID = new.VendorID
+			old.Vendor = new.Vendor
+		case fieldCECVersion:
+			old.CECVersion = new.CECVersion
+		case fieldMenuLanguage:
+			old.MenuLanguage = new.MenuLanguage
+		case fieldPowerStatus:
+			old.PowerStatus = new.PowerStatus
+		case fieldActiveSource:
+			old.ActiveSource = new.ActiveSource
+		case fieldDeviceType:
+			old.DeviceType = new.DeviceType
+		}
+	}
+	old.LastSeen = new.LastSeen
+	return old
+}
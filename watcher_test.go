@@ -0,0 +1,200 @@
+package cec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffDevices(t *testing.T) {
+	old := Device{
+		LogicalAddress:  TV,
+		DeviceType:      DeviceTypeTV,
+		OSDName:         "TV",
+		PowerStatus:     PowerStatusStandby,
+		PhysicalAddress: 0x0000,
+	}
+	new := old
+	new.PowerStatus = PowerStatusOn
+	new.OSDName = ""
+
+	changes := diffDevices(old, new)
+	if len(changes) != 1 {
+		t.Fatalf("diffDevices() = %+v, want a single change", changes)
+	}
+	change := changes[0]
+	if change.Field != "PowerStatus" || change.Old != PowerStatusStandby || change.New != PowerStatusOn {
+		t.Errorf("unexpected change %+v", change)
+	}
+
+	merged := mergeDevice(old, new)
+	if merged.PowerStatus != PowerStatusOn || merged.OSDName != "TV" {
+		t.Errorf("mergeDevice() = %+v, unknown properties should be kept", merged)
+	}
+
+	if changes := diffDevices(merged, new); len(changes) != 0 {
+		t.Errorf("diffDevices() = %+v after merge, want no changes", changes)
+	}
+}
+
+func TestMergeDevice(t *testing.T) {
+	seen := time.Now()
+	old := Device{
+		LogicalAddress:  Playback1,
+		DeviceType:      DeviceTypePlayback,
+		OSDName:         "Player",
+		Vendor:          "Sony",
+		VendorID:        0x080046,
+		PowerStatus:     PowerStatusOn,
+		PhysicalAddress: 0x1000,
+		CECVersion:      CECVersion1_4,
+		MenuLanguage:    "eng",
+	}
+	new := Device{
+		LogicalAddress:  Playback1,
+		DeviceType:      DeviceTypeUnknown,
+		Vendor:          "Philips",
+		VendorID:        0x00903E,
+		PowerStatus:     PowerStatusUnknown,
+		PhysicalAddress: 0x2000,
+		ActiveSource:    true,
+		LastSeen:        seen,
+	}
+
+	got := mergeDevice(old, new)
+	want := old
+	want.Vendor = "Philips"
+	want.VendorID = 0x00903E
+	want.PhysicalAddress = 0x2000
+	want.ActiveSource = true
+	want.LastSeen = seen
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeDevice() = %+v, want %+v", got, want)
+	}
+
+	// an invalid physical address is unknown, not a change
+	new = old
+	new.PhysicalAddress = InvalidPhysicalAddress
+	if got := mergeDevice(old, new); got.PhysicalAddress != 0x1000 {
+		t.Errorf("mergeDevice() took physical address %v", got.PhysicalAddress)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	bus := newFakeBus(Recording1)
+	bus.setPresent(TV, true)
+	bus.setPower(TV, PowerStatusStandby)
+	for _, msg := range []*Command{
+		command(TV, Broadcast, opcodeReportPhysicalAddress, 0x00, 0x00, 0x00),
+		command(TV, Recording1, opcodeSetOSDName, 'T', 'V'),
+		command(TV, Broadcast, opcodeDeviceVendorID, 0x00, 0x00, 0xF0),
+		command(TV, Recording1, opcodeCECVersion, byte(CECVersion1_4)),
+		command(TV, Broadcast, opcodeSetMenuLanguage, 'e', 'n', 'g'),
+		command(TV, Recording1, opcodeReportPowerStatus, byte(PowerStatusStandby)),
+	} {
+		bus.c.updateState(msg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := bus.c.Watch(ctx, WatchOptions{PollInterval: 10 * time.Millisecond, RescanInterval: -1})
+
+	next := func() DeviceEvent {
+		t.Helper()
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("events closed early")
+			}
+			return ev
+		case <-ctx.Done():
+			t.Fatal("no event")
+		}
+		return nil
+	}
+
+	added, ok := next().(DeviceAdded)
+	if !ok || added.Device.LogicalAddress != TV || added.Device.OSDName != "TV" || added.Device.Vendor != "Samsung" {
+		t.Fatalf("first event %+v, want the TV added", added)
+	}
+
+	bus.setPower(TV, PowerStatusOn)
+	changed, ok := next().(DeviceChanged)
+	if !ok || changed.Field != fieldPowerStatus || changed.Old != PowerStatusStandby || changed.New != PowerStatusOn {
+		t.Fatalf("event %+v, want the power status change", changed)
+	}
+
+	bus.setPresent(TV, false)
+	removed, ok := next().(DeviceRemoved)
+	if !ok || removed.Device.LogicalAddress != TV || removed.Device.PowerStatus != PowerStatusOn {
+		t.Fatalf("event %+v, want the TV removed", removed)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchActiveSource(t *testing.T) {
+	bus := newFakeBus(Recording1)
+	bus.setPresent(Playback1, true)
+	bus.c.hooks.activeSource = func(address LogicalAddress) bool { return address == Playback1 }
+	for _, msg := range []*Command{
+		command(Playback1, Broadcast, opcodeReportPhysicalAddress, 0x10, 0x00, 0x04),
+		command(Playback1, Recording1, opcodeSetOSDName, 'P', 'l', 'a', 'y'),
+		command(Playback1, Broadcast, opcodeDeviceVendorID, 0x08, 0x00, 0x46),
+		command(Playback1, Recording1, opcodeCECVersion, byte(CECVersion1_4)),
+		command(Playback1, Broadcast, opcodeSetMenuLanguage, 'e', 'n', 'g'),
+	} {
+		bus.c.updateState(msg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := bus.c.Watch(ctx, WatchOptions{PollInterval: time.Hour, RescanInterval: -1})
+
+	if added, ok := (<-events).(DeviceAdded); !ok || !added.Device.ActiveSource {
+		t.Fatalf("first event %+v, want Playback1 added as active source", added)
+	}
+
+	bus.receive(command(Playback1, Recording1, opcodeReportPowerStatus, byte(PowerStatusOn)))
+	if changed, ok := (<-events).(DeviceChanged); !ok || changed.Field != fieldPowerStatus {
+		t.Fatalf("event %+v, want the power status change", changed)
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected event %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bus := newFakeBus(Recording1)
+	events := bus.c.Watch(ctx, WatchOptions{})
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("event after ctx is done")
+		}
+	case <-time.After(time.Second):
+		t.Error("events not closed when ctx is done")
+	}
+
+	bus = newFakeBus(Recording1)
+	events = bus.c.Watch(context.Background(), WatchOptions{PollInterval: time.Hour, RescanInterval: -1})
+	bus.c.Close(context.Background())
+	select {
+	case <-events:
+		for range events {
+		}
+	case <-time.After(time.Second):
+		t.Error("events not closed when the connection is closed")
+	}
+}